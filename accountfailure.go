@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultMaxIdenticalFailures is how many channels in a row may fail with the
+// same error before the target account itself is assumed to be the problem.
+const defaultMaxIdenticalFailures = 5
+
+// failureTracker detects the target account being unable to subscribe to
+// anything, for example because it is suspended or terminated. Every insert
+// then fails with the same error, whichever channel it is for.
+type failureTracker struct {
+	limit int
+	last  string
+	count int
+}
+
+// failed records a failed insert and reports whether the same error has now
+// happened too many times in a row.
+func (tracker *failureTracker) failed(err error) bool {
+	if err.Error() == tracker.last {
+		tracker.count++
+	} else {
+		tracker.last = err.Error()
+		tracker.count = 1
+	}
+	return tracker.limit > 0 && tracker.count >= tracker.limit
+}
+
+func (tracker *failureTracker) succeeded() {
+	tracker.last = ""
+	tracker.count = 0
+}
+
+// accountFailureGuidance explains what to do when every insert fails with
+// err.
+func accountFailureGuidance(err error, count int) string {
+	guidance := fmt.Sprintf("The last %v channels all failed with the same error, so the problem is most likely the target account rather than the channels.\n", count)
+	return guidance + accountGuidance(err) + "\n" + stoppingGuidance
+}
+
+// targetAccountGuidance explains what to do when an insert fails because of
+// the target account itself.
+func targetAccountGuidance(err error) string {
+	return accountGuidance(err) + "\n" + stoppingGuidance
+}
+
+// stoppingGuidance ends the guidance, saying what happens to the transfer.
+const stoppingGuidance = "Stopping so no more quota is wasted, every channel not subscribed to is left pending."
+
+// isTargetAccountProblem reports whether an insert failed because of the
+// target account rather than the channel, so every other insert would too.
+func isTargetAccountProblem(err error) bool {
+	if errors.Is(err, errAuthorizationExpired) {
+		return true
+	}
+	switch errorReason(err) {
+	case "accountClosed", "accountSuspended", "youtubeSignupRequired", "subscriberNotFound", "subscriptionLimitExceeded", "tooManySubscriptions":
+		return true
+	}
+	return false
+}
+
+// accountGuidance explains what to do about the target account, going by
+// the reason it failed with.
+func accountGuidance(err error) string {
+	if errors.Is(err, errAuthorizationExpired) {
+		return "The target account's authorization has expired or been revoked. Run auth login target where a browser can be used, then run again."
+	}
+	switch errorReason(err) {
+	case "accountClosed":
+		return "The target account has been closed. Transfer to a different account instead."
+	case "accountSuspended":
+		return "The target account has been suspended. Check your email for a notice from YouTube or appeal at https://support.google.com/youtube/answer/2802168, then run again."
+	case "youtubeSignupRequired", "subscriberNotFound":
+		return "The target Google account doesn't have a YouTube channel yet. Sign in to https://www.youtube.com with it, create a channel and run again."
+	case "subscriptionLimitExceeded", "tooManySubscriptions":
+		return "The target account has reached the limit of channels it can subscribe to, or subscribed to too many recently. Wait a day or two, or unsubscribe from channels with prune, then run again."
+	default:
+		return "It may be suspended, terminated or missing a YouTube channel. Sign in to https://www.youtube.com with the target account and try subscribing to a channel by hand to find out, then run again."
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// authorizedChannel returns the channel the credentials of a service act as.
+// A Google account can hold several channels, such as brand accounts, and
+// the channel is picked when authorizing.
+func authorizedChannel(ctx context.Context, service *youtube.Service) (*youtube.Channel, error) {
+	response, err := service.Channels.List([]string{"snippet"}).Mine(true).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Items) == 0 {
+		return nil, nil
+	}
+	return response.Items[0], nil
+}
+
+// checkAccountChannel makes sure an account's credentials act as the
+// channel recorded for the account in the state, recording it if there is
+// none yet, so a transfer doesn't carry on into another channel of the same
+// Google account.
+func checkAccountChannel(ctx context.Context, service *youtube.Service, state *importState, account string) error {
+	channel, err := authorizedChannel(ctx, service)
+	if err != nil {
+		return err
+	}
+	if channel == nil {
+		return nil
+	}
+	if err := rememberAccountChannel(account, channel.Id); err != nil {
+		log.Printf("Unable to remember the %s account's channel: %v", account, err)
+	}
+
+	recorded := state.Accounts[account]
+	if recorded == "" {
+		if state.Accounts == nil {
+			state.Accounts = make(map[string]string)
+		}
+		state.Accounts[account] = channel.Id
+		return nil
+	}
+	if recorded != channel.Id {
+		return fmt.Errorf("the %s credentials are for the channel %s (%s), but the state file is for the channel %s. Run auth login -force %s and pick that channel",
+			account, channel.Snippet.Title, channel.Id, recorded, account)
+	}
+	return nil
+}
+
+// accountChannelsFile returns the file the channels of the authorized
+// accounts are remembered in, next to their credentials, so state files can
+// be named after them without calling the API. The directory may not exist
+// yet.
+func accountChannelsFile() (string, error) {
+	tokenCacheDir, err := tokenCacheDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(tokenCacheDir, "channels.json"), nil
+}
+
+// readAccountChannels returns the remembered channel IDs of the accounts.
+func readAccountChannels() map[string]string {
+	file, err := accountChannelsFile()
+	if err != nil {
+		return make(map[string]string)
+	}
+	return readAccountChannelsFile(file)
+}
+
+// readAccountChannelsFile returns the channel IDs remembered in file.
+func readAccountChannelsFile(file string) map[string]string {
+	channels := make(map[string]string)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return channels
+	}
+	json.Unmarshal(data, &channels)
+	return channels
+}
+
+// accountChannels returns the channel IDs of the accounts: the remembered
+// ones, with the source's being -source-channel-id if it is set.
+func accountChannels() map[string]string {
+	channels := readAccountChannels()
+	if sourceChannelID != "" {
+		channels["source"] = sourceChannelID
+	}
+	return channels
+}
+
+// rememberAccountChannel remembers the channel an account's credentials act
+// as.
+func rememberAccountChannel(account, channelID string) error {
+	// Credentials kept in the keyring leave the directory uncreated
+	if _, err := tokenCacheDir(); err != nil {
+		return err
+	}
+	file, err := accountChannelsFile()
+	if err != nil {
+		return err
+	}
+	return rememberAccountChannelIn(file, account, channelID)
+}
+
+// rememberAccountChannelIn remembers the channel an account's credentials
+// act as in file.
+func rememberAccountChannelIn(file, account, channelID string) error {
+	channels := readAccountChannelsFile(file)
+	if channels[account] == channelID {
+		return nil
+	}
+	channels[account] = channelID
+	return writeFileAtomically(file, 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(channels)
+	})
+}
+
+// lookUpStateFileAccounts looks up the channels of the accounts the state
+// file is named after that aren't remembered yet, authorizing them if
+// needed.
+func lookUpStateFileAccounts(ctx context.Context, service func(account string) *youtube.Service) error {
+	channels := accountChannels()
+	for _, account := range stateFileAccounts {
+		if !strings.Contains(stateFile, "{"+account+"}") || channels[account] != "" {
+			continue
+		}
+		channel, err := authorizedChannel(ctx, service(account))
+		if err != nil {
+			return err
+		}
+		if channel == nil {
+			return fmt.Errorf("the %s account has no YouTube channel to name the state file after", account)
+		}
+		if err := rememberAccountChannel(account, channel.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+)
+
+// userAgent identifies the tool in API requests, which helps when looking
+// into quota issues with Google.
+const userAgent = "youtube-subscriptions-transfer (+https://github.com/martinbjeldbak/youtube-subscriptions-transfer)"
+
+// quotaUser is sent as the quotaUser parameter of every API request, set
+// with -quota-user.
+var quotaUser string
+
+// sourceChannelID, set with -source-channel-id, is a channel whose public
+// subscriptions are the source instead of the source account's.
+var sourceChannelID string
+
+// addAPIFlags adds the flags configuring API requests to the flags of a
+// command calling the API.
+func addAPIFlags(flags *flag.FlagSet) {
+	flags.StringVar(&clientSecretFile, "client-secret", clientSecretFile, "OAuth client secret file of the API project")
+	flags.StringVar(&sourceClientSecretFile, "source-client-secret", "", "client secret file for the source account, if it uses another API project than -client-secret")
+	flags.StringVar(&sourceChannelID, "source-channel-id", "", "list the public subscriptions of this channel as the source instead of the source account's, with the target account's credentials so the source account needn't be authorized")
+	flags.StringVar(&targetClientSecretFile, "target-client-secret", "", "client secret file for the target account, if it uses another API project than -client-secret")
+	flags.BoolVar(&deviceAuth, "device-auth", deviceAuth, "authorize accounts by entering a code on another device, for machines without a browser")
+	flags.StringVar(&settings.CredentialsDir, "credentials-dir", settings.CredentialsDir, "directory the accounts' credentials are cached in (default the user's config directory)")
+	flags.BoolVar(&useKeyring, "keyring", useKeyring, "keep the accounts' credentials in the system keyring instead of files")
+	flags.StringVar(&quotaUser, "quota-user", "", "identifies the user to the API for per-user quota when several people share one API project, e.g. an email address or name")
+	addRetryFlags(flags)
+}
+
+// quotaUserTransport adds the quotaUser parameter to requests.
+type quotaUserTransport struct {
+	quotaUser string
+	base      http.RoundTripper
+}
+
+func (transport *quotaUserTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	query := request.URL.Query()
+	query.Set("quotaUser", transport.quotaUser)
+	request.URL.RawQuery = query.Encode()
+
+	return transport.base.RoundTrip(request)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomically writes a file by writing to a temporary file next to
+// it, syncing that to disk and renaming it into place, so a crash midway
+// leaves either the old or the new file but never a partially written one.
+func writeFileAtomically(file string, perm os.FileMode, write func(w io.Writer) error) error {
+	temp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Does nothing once renamed
+	defer os.Remove(temp.Name())
+
+	if err := write(temp); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), file)
+}
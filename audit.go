@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// defaultAuditLogFile is where changes to the accounts are logged unless
+// another file is chosen.
+const defaultAuditLogFile = "audit.jsonl"
+
+// auditLogFile is where every change made to an account is logged, nowhere
+// if empty.
+var auditLogFile = defaultAuditLogFile
+
+// addAuditLogFlag adds the -audit-log flag to the flags of a command
+// changing an account.
+func addAuditLogFlag(flags *flag.FlagSet) {
+	flags.StringVar(&auditLogFile, "audit-log", auditLogFile, "file every subscribe and unsubscribe call is logged to as a line of JSON, empty to not log them")
+}
+
+// auditEntry is a line of the audit log, describing a single call that
+// changed, or tried to change, an account's subscriptions.
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	// Action is subscribe or unsubscribe
+	Action string `json:"action"`
+	// Account is the account changed and AccountChannel the ID of its
+	// channel, if known
+	Account        string `json:"account"`
+	AccountChannel string `json:"accountChannel,omitempty"`
+	// Run is the number of the transfer run in the state file, as listed
+	// by history
+	Run int `json:"run,omitempty"`
+	// RunID is the ID of the run, which unlike its number is unique across
+	// state files
+	RunID     string `json:"runId,omitempty"`
+	ChannelID string `json:"channelId"`
+	Title     string `json:"title,omitempty"`
+	// SubscriptionID is the subscription created or deleted
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	// Result is imported, duplicate, unavailable, failed, quotaExceeded,
+	// rateLimited or budgetSpent for subscribing, and unsubscribed,
+	// notFound or failed for unsubscribing
+	Result    string `json:"result"`
+	QuotaCost int    `json:"quotaCost"`
+	Error     string `json:"error,omitempty"`
+}
+
+// auditLog appends entries to an audit log file. It is never rewritten, so
+// it tells what was done to the accounts even if the state file is lost.
+type auditLog struct {
+	file string
+	mu   sync.Mutex
+}
+
+// newAuditLog returns the audit log kept in file, or nil if file is empty.
+func newAuditLog(file string) *auditLog {
+	if file == "" {
+		return nil
+	}
+	return &auditLog{file: file}
+}
+
+// record appends an entry to the log, doing nothing for a nil log.
+func (log *auditLog) record(entry auditEntry) error {
+	if log == nil {
+		return nil
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(log.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordUnsubscribe logs unsubscribing an account from a subscription,
+// warning if it can't be logged.
+func recordUnsubscribe(audit *auditLog, account string, subscription *youtube.Subscription, err error) {
+	entry := auditEntry{Action: "unsubscribe", Account: account, AccountChannel: readAccountChannels()[account],
+		ChannelID: subscription.Snippet.ResourceId.ChannelId, Title: subscription.Snippet.Title,
+		SubscriptionID: subscription.Id, Result: "unsubscribed", QuotaCost: subscriptionDeleteCost}
+	if isNotFound(err) {
+		// Already gone, so there is nothing left to unsubscribe from
+		entry.Result = "notFound"
+		entry.Error = err.Error()
+	} else if err != nil {
+		entry.Result = "failed"
+		entry.Error = err.Error()
+	}
+	if err := audit.record(entry); err != nil {
+		fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to write to the audit log: %v", err)))
+	}
+}
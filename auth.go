@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/term"
+	"google.golang.org/api/youtube/v3"
+)
+
+// accountScopes are the scopes each account is authorized with.
+var accountScopes = map[string]string{
+	"source": youtube.YoutubeReadonlyScope,
+	"target": youtube.YoutubeForceSslScope,
+}
+
+// authAccounts are the kinds of credentials that can be cached: the source
+// and target accounts, and the source account allowed to unsubscribe.
+var authAccounts = []string{"source", "target", "source-manage"}
+
+// authCommands manage the cached credentials: login authorizes accounts,
+// which is also what auth does without a subcommand, status shows them and
+// revoke removes them.
+var authCommands = map[string]command{
+	"login":  {flags: authLoginCommand},
+	"status": {flags: authStatusCommand},
+	"revoke": {flags: authRevokeCommand},
+}
+
+// authLoginCommand authorizes the named accounts, or both, so later commands
+// can run unattended. Accounts already authorized are left alone unless
+// -force is passed.
+func authLoginCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("auth", flag.ExitOnError)
+	force := flags.Bool("force", false, "authorize again even if credentials are cached")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s auth login|status|revoke [FLAGS] [source] [target]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		accounts := flags.Args()
+		if len(accounts) == 0 {
+			accounts = []string{"source", "target"}
+		}
+
+		ctx := context.Background()
+		for _, account := range accounts {
+			scope, ok := accountScopes[account]
+			if !ok {
+				flags.Usage()
+				os.Exit(2)
+			}
+
+			if *force {
+				if err := deleteToken(account); err != nil {
+					log.Fatalf("Unable to remove cached credentials: %v", err)
+				}
+			}
+
+			for {
+				service := getService(ctx, account, scope)
+				channel, err := authorizedChannel(ctx, service)
+				if err != nil {
+					log.Fatalf("Unable to look up the %s account: %v", account, err)
+				}
+				if channel == nil {
+					fmt.Printf("The %s account is authorized as an account without a channel\n", account)
+					break
+				}
+				fmt.Printf("The %s account is authorized as the channel %s (%s)\n", account, channel.Snippet.Title, channel.Id)
+
+				// A Google account with several channels asks which one to use
+				// when authorizing, so picking another means authorizing again
+				if term.IsTerminal(int(os.Stdin.Fd())) && !confirm(fmt.Sprintf("Use %s as the %s channel?", channel.Snippet.Title, account)) {
+					fmt.Println("Authorize again and pick the other channel when Google asks which one to use")
+					if err := deleteToken(account); err != nil {
+						log.Fatalf("Unable to remove cached credentials: %v", err)
+					}
+					continue
+				}
+
+				recordAccountChannel(account, channel.Id)
+				break
+			}
+		}
+	}
+}
+
+// recordAccountChannel remembers the channel picked for an account and
+// stores it in the state file, if a transfer has been started.
+func recordAccountChannel(account, channelID string) {
+	if err := rememberAccountChannel(account, channelID); err != nil {
+		log.Fatalf("Unable to remember the %s account's channel: %v", account, err)
+	}
+
+	state, err := readStateFromFile(stateFile)
+	if err != nil {
+		return
+	}
+	if state.Accounts == nil {
+		state.Accounts = make(map[string]string)
+	}
+	if state.Accounts[account] == channelID {
+		return
+	}
+	state.Accounts[account] = channelID
+	if err := writeStateToFile(stateFile, state); err != nil {
+		log.Fatalf("Unable to save state: %v", err)
+	}
+}
+
+// tokenInfo is what Google's tokeninfo endpoint says about an access token.
+type tokenInfo struct {
+	Scope string `json:"scope"`
+}
+
+// authStatusCommand shows which accounts have cached credentials, whether
+// they still work, their scopes and when the access token expires.
+func authStatusCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("auth", flag.ExitOnError)
+	addAPIFlags(flags)
+	return flags, func(args []string) {
+		ctx := context.Background()
+		for _, account := range authAccounts {
+			token, err := loadToken(account)
+			if err != nil {
+				if account != "source-manage" {
+					fmt.Printf("%s: not authorized\n", account)
+				}
+				continue
+			}
+
+			kind := "target"
+			if strings.HasPrefix(account, "source") {
+				kind = "source"
+			}
+			config, err := google.ConfigFromJSON(readClientSecret(kind))
+			if err != nil {
+				log.Fatalf("Unable to parse client secret file to config: %v", err)
+			}
+
+			refreshed, err := config.TokenSource(ctx, token).Token()
+			if isInvalidGrant(err) {
+				fmt.Printf("%s: authorization expired or revoked, run auth login -force %s\n", account, account)
+				continue
+			} else if err != nil {
+				fmt.Printf("%s: unable to refresh the access token: %v\n", account, err)
+				continue
+			}
+
+			info, err := lookUpToken(ctx, refreshed.AccessToken)
+			if err != nil {
+				fmt.Printf("%s: authorized, unable to look up the token: %v\n", account, err)
+				continue
+			}
+			fmt.Printf("%s: authorized, access token expires %s, scopes %s\n", account, formatDateTime(refreshed.Expiry), info.Scope)
+		}
+	}
+}
+
+// lookUpToken asks Google about an access token.
+func lookUpToken(ctx context.Context, accessToken string) (*tokenInfo, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://oauth2.googleapis.com/tokeninfo?access_token="+url.QueryEscape(accessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo responded %s", response.Status)
+	}
+
+	info := &tokenInfo{}
+	return info, json.NewDecoder(response.Body).Decode(info)
+}
+
+// authRevokeCommand revokes the named accounts' credentials with Google and
+// removes them from the cache.
+func authRevokeCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("auth", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s auth revoke [FLAGS] ACCOUNT...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	addAPIFlags(flags)
+	return flags, func(args []string) {
+		if flags.NArg() == 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		ctx := context.Background()
+		for _, account := range flags.Args() {
+			token, err := loadToken(account)
+			if err != nil {
+				fmt.Printf("%s: not authorized\n", account)
+				continue
+			}
+
+			revoke := token.RefreshToken
+			if revoke == "" {
+				revoke = token.AccessToken
+			}
+			if err := revokeToken(ctx, revoke); err != nil {
+				fmt.Printf("%s: unable to revoke the token with Google, removing it anyway: %v\n", account, err)
+			}
+			if err := deleteToken(account); err != nil {
+				log.Fatalf("Unable to remove cached credentials: %v", err)
+			}
+			fmt.Printf("%s: revoked\n", account)
+		}
+	}
+}
+
+// revokeToken revokes a refresh or access token, which also revokes the
+// tokens issued along with it.
+func revokeToken(ctx context.Context, token string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://oauth2.googleapis.com/revoke",
+		strings.NewReader(url.Values{"token": {token}}.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// Tokens that are already revoked or expired are rejected as invalid
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("revoke responded %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// readBookmarks finds the YouTube channels linked to in a bookmarks file in
+// the Netscape bookmark format browsers export bookmarks as. Links to
+// anything other than a channel are ignored.
+func readBookmarks(file string, options importOptions) ([]channelReference, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var references []channelReference
+
+	tokenizer := html.NewTokenizer(f)
+	var current *channelReference
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, err
+			}
+			return references, nil
+		case html.StartTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			if string(name) != "a" || !hasAttributes {
+				continue
+			}
+			for {
+				key, value, more := tokenizer.TagAttr()
+				if string(key) == "href" {
+					if reference, ok := parseChannelURL(string(value)); ok {
+						references = append(references, reference)
+						current = &references[len(references)-1]
+					}
+				}
+				if !more {
+					break
+				}
+			}
+		case html.TextToken:
+			if current != nil {
+				current.title += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "a" && current != nil {
+				current.title = strings.TrimSpace(current.title)
+				current = nil
+			}
+		}
+	}
+}
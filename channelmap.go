@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readChannelMap parses a channel remapping file. Each non-empty line maps a
+// source channel ID to the channel ID the target account should subscribe to
+// instead, written either as "OLD_ID NEW_ID" or "OLD_ID -> NEW_ID". Lines
+// starting with # are ignored.
+func readChannelMap(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	channelMap := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(strings.Replace(line, "->", " ", 1))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"OLD_ID -> NEW_ID\", got %q", file, lineNumber, line)
+		}
+		channelMap[fields[0]] = fields[1]
+	}
+
+	return channelMap, scanner.Err()
+}
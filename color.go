@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+)
+
+// colorsEnabled is whether output is colored: only when writing to a
+// terminal that supports it, and NO_COLOR (https://no-color.org) isn't set.
+var colorsEnabled = term.IsTerminal(int(os.Stdout.Fd())) &&
+	os.Getenv("NO_COLOR") == "" &&
+	os.Getenv("TERM") != "dumb" &&
+	enableVirtualTerminal() == nil
+
+// colorize returns text in the given ANSI color, if colors are enabled.
+func colorize(color, text string) string {
+	if !colorsEnabled {
+		return text
+	}
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// commandFlags returns the flags of a command, from the command's own flag
+// definitions, without running it.
+func commandFlags(name string) (flags []*flag.Flag) {
+	set, _ := commands[name].flags()
+	set.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// completion is added to the commands here, as it refers to them itself.
+func init() {
+	commands["completion"] = command{completionCommand, "print a shell completion script"}
+}
+
+// channelCommands take channel IDs in the state file as arguments.
+var channelCommands = []string{"mark-imported", "mark-pending"}
+
+// completionCommand prints a completion script for a shell, or with
+// "channels" the channel IDs in the state file for the scripts to offer.
+func completionCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("completion", flag.ExitOnError)
+	addStateFileFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s completion bash|zsh|fish\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		program := filepath.Base(os.Args[0])
+		switch flags.Arg(0) {
+		case "bash":
+			printBashCompletion(program)
+		case "zsh":
+			fmt.Println("autoload -U +X bashcompinit && bashcompinit")
+			printBashCompletion(program)
+		case "fish":
+			printFishCompletion(program)
+		case "channels":
+			askStatePassphrase = false
+			state, err := readStateFromFile(stateFile)
+			if err != nil {
+				return
+			}
+			for _, channelStatus := range state.Channels {
+				fmt.Println(channelStatus.Channel.Snippet.ResourceId.ChannelId)
+			}
+		default:
+			flags.Usage()
+			os.Exit(2)
+		}
+	}
+}
+
+func flagNames(flags []*flag.Flag) string {
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func printBashCompletion(program string) {
+	function := "_" + nonIdentifier.ReplaceAllString(program, "_")
+
+	fmt.Printf("%s() {\n", function)
+	fmt.Println(`	local cur="${COMP_WORDS[COMP_CWORD]}" command="${COMP_WORDS[1]}" words`)
+	fmt.Println(`	if [ "$COMP_CWORD" -eq 1 ] && [[ "$cur" != -* ]]; then`)
+	fmt.Printf("\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(commandNames(), " "))
+	fmt.Println("\t\treturn")
+	fmt.Println("\tfi")
+	fmt.Println(`	case "$command" in`)
+	for _, name := range commandNames() {
+		fmt.Printf("\t%s) words=%q ;;\n", name, flagNames(commandFlags(name)))
+	}
+	fmt.Printf("\t*) words=%q ;;\n", flagNames(commandFlags("transfer")))
+	fmt.Println("\tesac")
+	fmt.Printf("\tcase \"$command\" in %s)\n", strings.Join(channelCommands, "|"))
+	fmt.Printf("\t\t[[ \"$cur\" != -* ]] && words=\"$(%s completion channels 2>/dev/null)\" ;;\n", program)
+	fmt.Println("\tesac")
+	fmt.Println(`	COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Println("}")
+	fmt.Printf("complete -o default -F %s %s\n", function, program)
+}
+
+func printFishCompletion(program string) {
+	quote := func(text string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text) + "'"
+	}
+
+	for _, name := range commandNames() {
+		fmt.Printf("complete -c %s -n __fish_use_subcommand -a %s -d %s\n", program, name, quote(commands[name].description))
+	}
+	for _, f := range commandFlags("transfer") {
+		fmt.Printf("complete -c %s -n __fish_use_subcommand -o %s -d %s\n", program, f.Name, quote(f.Usage))
+	}
+	for _, name := range commandNames() {
+		for _, f := range commandFlags(name) {
+			fmt.Printf("complete -c %s -n '__fish_seen_subcommand_from %s' -o %s -d %s\n", program, name, f.Name, quote(f.Usage))
+		}
+	}
+	fmt.Printf("complete -c %s -n '__fish_seen_subcommand_from %s' -a '(%s completion channels 2>/dev/null)'\n",
+		program, strings.Join(channelCommands, " "), program)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configName is the config file's path within the user's config directory,
+// e.g. ~/.config/youtube-subscriptions-transfer/config.yaml on Linux.
+const configName = "youtube-subscriptions-transfer/config.yaml"
+
+// config is the config file. Besides the file locations, it holds default
+// flag values for each command, set under the command's name:
+//
+//	client-secret: ~/secrets/client_secret.json
+//	state-file: ~/transfers/importStatus.json
+//	transfer:
+//	  subscribed-after: 2022-01-01
+//	  daily-quota: 20000
+//	  notify:
+//	    - webhook=https://example.com/hook
+//
+// Environment variables override the values in the file, and flags given on
+// the command line override both.
+type config struct {
+	ClientSecret       string `yaml:"client-secret"`
+	SourceClientSecret string `yaml:"source-client-secret"`
+	TargetClientSecret string `yaml:"target-client-secret"`
+	CredentialsDir     string `yaml:"credentials-dir"`
+	StateFile          string `yaml:"state-file"`
+
+	Other map[string]interface{} `yaml:",inline"`
+	// Commands are the flag values of each command
+	Commands map[string]map[string]interface{} `yaml:"-"`
+}
+
+// settings is the loaded config file, empty if there is none.
+var settings = &config{}
+
+// envPrefix starts the names of the environment variables settings can be
+// given in, e.g. YOUTUBE_SUBSCRIPTIONS_TRANSFER_STATE_FILE for state-file.
+const envPrefix = "YOUTUBE_SUBSCRIPTIONS_TRANSFER_"
+
+// envName returns the environment variable a setting or flag is read from.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// configFile returns the path of the config file.
+func configFile() (string, error) {
+	if file := os.Getenv(envName("config")); file != "" {
+		return file, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, configName), nil
+}
+
+// loadConfig reads the config file into settings, overrides its locations
+// with the ones set in the environment and points the file locations at
+// them. A missing config file is only an error when one was chosen in the
+// environment.
+func loadConfig() error {
+	if file, err := configFile(); err == nil {
+		loaded, err := readConfig(file)
+		if err == nil {
+			settings = loaded
+		} else if !errors.Is(err, os.ErrNotExist) || os.Getenv(envName("config")) != "" {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	for name, location := range map[string]*string{
+		"client-secret":        &settings.ClientSecret,
+		"source-client-secret": &settings.SourceClientSecret,
+		"target-client-secret": &settings.TargetClientSecret,
+		"credentials-dir":      &settings.CredentialsDir,
+		"state-file":           &settings.StateFile,
+	} {
+		if value := os.Getenv(envName(name)); value != "" {
+			*location = value
+		}
+	}
+
+	if settings.ClientSecret != "" {
+		clientSecretFile = expandHome(settings.ClientSecret)
+	}
+	if settings.SourceClientSecret != "" {
+		sourceClientSecretFile = expandHome(settings.SourceClientSecret)
+	}
+	if settings.TargetClientSecret != "" {
+		targetClientSecretFile = expandHome(settings.TargetClientSecret)
+	}
+	if settings.StateFile != "" {
+		stateFile = expandHome(settings.StateFile)
+	}
+	return nil
+}
+
+func readConfig(file string) (*config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	loaded := &config{}
+	if err := yaml.NewDecoder(f).Decode(loaded); err != nil {
+		return nil, err
+	}
+
+	loaded.Commands = make(map[string]map[string]interface{})
+	for name, value := range loaded.Other {
+		if _, ok := commands[name]; !ok {
+			return nil, fmt.Errorf("unknown setting or command %q", name)
+		}
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s should hold the command's flags, e.g. %s: {FLAG: VALUE}", name, name)
+		}
+		loaded.Commands[name] = values
+	}
+	return loaded, nil
+}
+
+// expandHome replaces a leading ~ in a path with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, path[1:])
+}
+
+// passedFlags are the names of the flags passed on the command line, rather
+// than set in the config file or environment, as found by parseFlags.
+var passedFlags = make(map[string]bool)
+
+// parseFlags sets the flags to the values the config file has for the
+// command, then to the values set in the environment, then parses the
+// command line over them. Flags that can be repeated take the values of
+// the last of these that gives any, rather than adding them up.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	values := settings.Commands[flags.Name()]
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			log.Fatalf("Invalid config file: %s has no setting %q", flags.Name(), name)
+		}
+
+		// Lists set flags that can be repeated once for each item
+		items, ok := values[name].([]interface{})
+		if !ok {
+			items = []interface{}{values[name]}
+		}
+		for _, item := range items {
+			if err := flags.Set(name, fmt.Sprint(item)); err != nil {
+				log.Fatalf("Invalid config file: %s %s: %v", flags.Name(), name, err)
+			}
+		}
+	}
+
+	restore := replaceRepeatedFlags(flags)
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+
+		// Flags that can be repeated take space separated values
+		items := []string{value}
+		if _, repeated := f.Value.(*replacingFlag); repeated {
+			items = strings.Fields(value)
+		}
+		for _, item := range items {
+			if err := flags.Set(f.Name, item); err != nil {
+				log.Fatalf("Invalid %s: %v", envName(f.Name), err)
+			}
+		}
+	})
+	restore()
+
+	restore = replaceRepeatedFlags(flags)
+	flags.Parse(args)
+	restore()
+	passedFlags = commandLineFlags(flags, args)
+}
+
+// commandLineFlags returns the names of the flags passed in args, by
+// parsing them again into flags that only note being set.
+func commandLineFlags(flags *flag.FlagSet, args []string) map[string]bool {
+	noted := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	noted.SetOutput(io.Discard)
+	flags.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		noted.Var(notedFlag(ok && boolFlag.IsBoolFlag()), f.Name, "")
+	})
+	noted.Parse(args)
+
+	passed := make(map[string]bool)
+	noted.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+	return passed
+}
+
+// notedFlag is a flag that ignores its values, a bool flag if true.
+type notedFlag bool
+
+func (notedFlag) String() string { return "" }
+
+func (notedFlag) Set(value string) error { return nil }
+
+func (isBool notedFlag) IsBoolFlag() bool { return bool(isBool) }
+
+// replacingFlag is a repeatedFlag whose first value replaces the values it
+// already has, and the following ones are added to it.
+type replacingFlag struct {
+	values   *repeatedFlag
+	replaced bool
+}
+
+func (flag *replacingFlag) String() string {
+	if flag.values == nil {
+		return ""
+	}
+	return flag.values.String()
+}
+
+func (flag *replacingFlag) Set(value string) error {
+	if !flag.replaced {
+		*flag.values = nil
+		flag.replaced = true
+	}
+	return flag.values.Set(value)
+}
+
+// replaceRepeatedFlags makes the flags that can be repeated replace their
+// values with the next ones they are given, until restore is called.
+func replaceRepeatedFlags(flags *flag.FlagSet) (restore func()) {
+	var replaced []*flag.Flag
+	flags.VisitAll(func(f *flag.Flag) {
+		if values, ok := f.Value.(*repeatedFlag); ok {
+			f.Value = &replacingFlag{values: values}
+			replaced = append(replaced, f)
+		}
+	})
+	return func() {
+		for _, f := range replaced {
+			f.Value = f.Value.(*replacingFlag).values
+		}
+	}
+}
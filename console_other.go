@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// enableVirtualTerminal is a no-op, terminals process ANSI escape sequences
+// out of the box outside of Windows.
+func enableVirtualTerminal() error {
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// dashboardBar is a labelled bar of a dashboard chart.
+type dashboardBar struct {
+	Label string
+	Value int
+	// Percent is the bar's length relative to the chart's longest bar
+	Percent float64
+}
+
+// dashboardData is what the dashboard page shows.
+type dashboardData struct {
+	Imported, Total int
+	Runs            []RunRecord
+	ImportedPerDay  []dashboardBar
+	QuotaPerDay     []dashboardBar
+	Errors          []dashboardBar
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>YouTube subscriptions transfer</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: 0.2em 0.5em; text-align: left; }
+td.bar div { background: #c00; height: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Imported}} of {{.Total}} channels imported</h1>
+{{define "chart"}}<table>{{range .}}
+<tr><td>{{.Label}}</td><td>{{.Value}}</td><td class="bar" style="width: 60%"><div style="width: {{printf "%.1f" .Percent}}%"></div></td></tr>{{else}}
+<tr><td>Nothing yet</td></tr>{{end}}
+</table>{{end}}
+<h2>Channels imported per day</h2>
+{{template "chart" .ImportedPerDay}}
+<h2>Quota used per day</h2>
+{{template "chart" .QuotaPerDay}}
+<h2>Errors</h2>
+{{template "chart" .Errors}}
+<h2>Runs</h2>
+<table>
+<tr><th>Started</th><th>Imported</th><th>Failed</th><th>Quota</th><th>Label</th></tr>{{range .Runs}}
+<tr><td>{{.Started.Format "2006-01-02 15:04"}}</td><td>{{.Imported}}</td><td>{{.Failed}}</td><td>{{.QuotaUsed}}{{if .QuotaExceeded}} (exceeded){{end}}</td><td>{{.Label}}</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// dashboardCommand serves a page charting the runs in the state file, read
+// afresh on every request so it follows a transfer running alongside it.
+func dashboardCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	listen := flags.String("listen", "localhost:8080", "address to serve the dashboard on")
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+
+			state, err := readStateFromFile(stateFile)
+			if os.IsNotExist(err) {
+				state = &importState{}
+			} else if err != nil {
+				http.Error(w, fmt.Sprintf("Unable to read state file: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			if err := dashboardTemplate.Execute(w, newDashboardData(state)); err != nil {
+				log.Printf("Unable to render dashboard: %v", err)
+			}
+		})
+
+		fmt.Printf("Serving the dashboard on http://%s\n", *listen)
+		log.Fatal(http.ListenAndServe(*listen, nil))
+	}
+}
+
+func newDashboardData(state *importState) dashboardData {
+	data := dashboardData{Total: len(state.Channels), Runs: state.Runs}
+	for _, channelStatus := range state.Channels {
+		if channelStatus.Imported {
+			data.Imported++
+		}
+	}
+
+	imported := make(map[string]int)
+	quota := make(map[string]int)
+	errorCounts := make(map[string]int)
+	for _, run := range state.Runs {
+		day := run.Started.Format("2006-01-02")
+		imported[day] += run.Imported
+		quota[day] += run.QuotaUsed
+		for _, message := range run.Errors {
+			// errors are recorded as "CHANNEL_ID: error", group them by error
+			if _, reason, ok := strings.Cut(message, ": "); ok {
+				message = reason
+			}
+			errorCounts[message]++
+		}
+	}
+
+	data.ImportedPerDay = dashboardBars(imported, false)
+	data.QuotaPerDay = dashboardBars(quota, false)
+	data.Errors = dashboardBars(errorCounts, true)
+	return data
+}
+
+// dashboardBars turns counts into bars ordered by label, or by value when
+// byValue is set.
+func dashboardBars(counts map[string]int, byValue bool) []dashboardBar {
+	var bars []dashboardBar
+	longest := 0
+	for label, value := range counts {
+		bars = append(bars, dashboardBar{Label: label, Value: value})
+		if value > longest {
+			longest = value
+		}
+	}
+
+	sort.Slice(bars, func(i, j int) bool {
+		if byValue && bars[i].Value != bars[j].Value {
+			return bars[i].Value > bars[j].Value
+		}
+		return bars[i].Label < bars[j].Label
+	})
+
+	for i := range bars {
+		if longest > 0 {
+			bars[i].Percent = float64(bars[i].Value) / float64(longest) * 100
+		}
+	}
+	return bars
+}
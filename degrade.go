@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// isPermissionDenied reports whether a call failed because the credential
+// isn't allowed what was asked for, such as a part or a scope that wasn't
+// granted, in which case asking for less may still work. Running out of
+// quota or being rate limited is forbidden too, but asking for less won't
+// help with that.
+func isPermissionDenied(err error) bool {
+	var apiError *googleapi.Error
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	switch errorReason(err) {
+	case "insufficientPermissions", "forbidden", "accessNotConfigured":
+		return true
+	case "authError":
+		return apiError.Code == http.StatusUnauthorized
+	}
+	return false
+}
+
+// isNotFound reports whether a call failed because what it asked for
+// doesn't exist.
+func isNotFound(err error) bool {
+	var apiError *googleapi.Error
+	return errors.As(err, &apiError) && apiError.Code == http.StatusNotFound
+}
+
+// errorReason returns the reason the API gave for an error, such as
+// quotaExceeded, or "" if it gave none.
+func errorReason(err error) string {
+	var apiError *googleapi.Error
+	if !errors.As(err, &apiError) {
+		return ""
+	}
+	for _, item := range apiError.Errors {
+		if item.Reason != "" {
+			return item.Reason
+		}
+	}
+	return ""
+}
+
+// isPermanentFailure reports whether subscribing failed in a way retrying
+// won't fix: the channel is gone or doesn't allow subscribing to it.
+func isPermanentFailure(err error) bool {
+	return channelRefusal(err) != "" || isNotFound(err)
+}
+
+// channelRefusal describes why subscribing failed if the API gave a reason
+// that is down to the channel rather than the target account, or returns ""
+// if it didn't.
+func channelRefusal(err error) string {
+	switch errorReason(err) {
+	case "publisherNotFound", "channelNotFound":
+		return "the channel has been deleted or terminated"
+	case "channelClosed", "channelSuspended":
+		return "the channel has been closed or suspended"
+	case "subscriptionForbidden":
+		return "the channel doesn't allow subscribing to it"
+	}
+	return ""
+}
+
+// mayBeUnavailable reports whether subscribing failed in a way a channel
+// blocked or hidden in the target account's region fails: the channel isn't
+// found or subscribing to it is forbidden.
+func mayBeUnavailable(err error) bool {
+	var apiError *googleapi.Error
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	for _, item := range apiError.Errors {
+		switch item.Reason {
+		case "publisherNotFound", "channelNotFound", "subscriptionForbidden":
+			return true
+		}
+	}
+	return apiError.Code == http.StatusNotFound
+}
+
+// subscriptionsWithFallback lists the subscriptions of the channel, or of
+// the account if channelID is empty, with the given parts. If those parts
+// are denied, it warns and falls back to listing only the snippet, which is
+// all a transfer needs.
+func subscriptionsWithFallback(ctx context.Context, service *youtube.Service, channelID string, parts []string) ([]*youtube.Subscription, error) {
+	list := func(parts []string) ([]*youtube.Subscription, error) {
+		if channelID != "" {
+			return channelSubscriptions(ctx, service, channelID, parts)
+		}
+		return mySubscriptions(ctx, service, parts)
+	}
+
+	subscriptions, err := list(parts)
+	if err == nil || len(subscriptions) > 0 || !isPermissionDenied(err) || len(parts) == 1 && parts[0] == "snippet" {
+		return subscriptions, err
+	}
+
+	fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to list the %s of subscriptions, falling back to only their snippet: %v", strings.Join(parts, ", "), err)))
+	return list([]string{"snippet"})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// desktopNotifierTitle is the title of desktop notifications.
+const desktopNotifierTitle = "YouTube subscriptions transfer"
+
+// windowsToastScript shows a Windows toast notification with the title and
+// body in the NOTIFY_TITLE and NOTIFY_BODY environment variables, as
+// PowerShell, which needs no registration of its own to show them.
+const windowsToastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:NOTIFY_TITLE)) | Out-Null
+$text.Item(1).AppendChild($template.CreateTextNode($env:NOTIFY_BODY)) | Out-Null
+$notifier = [Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe')
+$notifier.Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// desktopNotifier shows a notification on the desktop whenever the state of
+// the transfer changes, with notify-send on Linux and BSD, osascript on
+// macOS and PowerShell on Windows.
+type desktopNotifier struct{}
+
+func newDesktopNotifier(target string, options notifierOptions) (notifier, error) {
+	if target != "" {
+		return nil, errors.New("expected no target, e.g. desktop")
+	}
+	command := "notify-send"
+	switch runtime.GOOS {
+	case "windows":
+		command = "powershell"
+	case "darwin":
+		command = "osascript"
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return nil, err
+	}
+	return onStateChange(desktopNotifier{}), nil
+}
+
+func (desktopNotifier) notify(update progressUpdate) error {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		command = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+		command.Env = append(os.Environ(), "NOTIFY_TITLE="+desktopNotifierTitle, "NOTIFY_BODY="+update.summary())
+	case "darwin":
+		// Passed as arguments rather than in the script, so nothing in them
+		// needs quoting
+		command = exec.Command("osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			desktopNotifierTitle, update.summary())
+	default:
+		command = exec.Command("notify-send", "--app-name", desktopNotifierTitle, desktopNotifierTitle, update.summary())
+	}
+	return command.Run()
+}
+
+func (desktopNotifier) close() {}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// diffCommand lists the channels added to a SQLite state file, or whose
+// status changed, since a run started or a date, by default since the last
+// run started. Only SQLite state files keep when channels were added and
+// changed.
+func diffCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	runNumber := flags.Int("run", 0, "list the changes since this run started, numbered as listed by history (0 for the last run)")
+	sinceDate := flags.String("since", "", "list the changes since this date instead of a run, e.g. 2024-06-30")
+	addStateFileFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [FLAGS]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		if flags.NArg() > 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		file, err := resolveStateFile(stateFile)
+		if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+		if !isSQLiteStateFile(file) {
+			log.Fatalf("diff needs a SQLite state file, which keeps when channels were added and changed, pass -state-file with a .db file")
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			fmt.Println("No transfer has been started yet")
+			return
+		}
+
+		since, err := parseDate(*sinceDate)
+		if err != nil {
+			log.Fatalf("Unable to parse -since: %v", err)
+		}
+		if *sinceDate == "" {
+			since, err = sqliteRunStarted(file, *runNumber)
+			if err == sql.ErrNoRows && *runNumber == 0 {
+				fmt.Println("No runs yet")
+				return
+			} else if err == sql.ErrNoRows {
+				log.Fatalf("The state file has no run %d, see history for its runs", *runNumber)
+			} else if err != nil {
+				log.Fatalf("Unable to read state file: %v", err)
+			}
+		}
+
+		changes, err := sqliteChangesSince(file, since)
+		if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		fmt.Printf("Changes since %s:\n", formatDateTime(since.In(time.Local)))
+		if len(changes) == 0 {
+			fmt.Println("  none")
+		}
+		for _, change := range changes {
+			status := "pending"
+			switch {
+			case change.imported:
+				status = "imported"
+			case change.unavailable:
+				status = "unavailable"
+			}
+			verb := "now"
+			if change.added {
+				verb = "added,"
+			}
+			fmt.Printf("  %s (%s) %s %s\n", change.title, change.channelID, verb, status)
+		}
+	}
+}
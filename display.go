@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// titleWidth is how many terminal columns channel titles take up in progress
+// output, so whatever is printed after them lines up.
+const titleWidth = 40
+
+// plainOutput is whether output is kept simple for screen readers and dumb
+// terminals: no colors, no padding to line things up, and each status
+// printed as one complete line.
+var plainOutput = os.Getenv("TERM") == "dumb"
+
+// setPlainOutput turns on plain output.
+func setPlainOutput() {
+	plainOutput = true
+	colorsEnabled = false
+}
+
+// displayTitle truncates or pads a channel title to exactly width terminal
+// columns. Wide characters such as CJK and most emoji take up two columns,
+// combining characters none. In plain output the title is left whole.
+func displayTitle(title string, width int) string {
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, title)
+
+	if plainOutput {
+		return title
+	}
+	return runewidth.FillRight(runewidth.Truncate(title, width, "…"), width)
+}
+
+// channelNumber formats the position of a channel in a list of total
+// channels as #index/last, padded to the same width for every channel
+// unless output is plain.
+func channelNumber(index, total int) string {
+	last := strconv.Itoa(total - 1)
+	if plainOutput {
+		return fmt.Sprintf("#%d/%s", index, last)
+	}
+	return fmt.Sprintf("#%*d/%s", len(last), index, last)
+}
+
+// statusLine is a line of progress output that is finished once the outcome
+// is known. Its start is normally printed right away, showing what is being
+// waited on, but in plain output the line is only printed once finished, so
+// screen readers read it as one message.
+type statusLine struct {
+	pending string
+}
+
+func startStatusLine(text string) *statusLine {
+	line := &statusLine{}
+	line.print(text)
+	return line
+}
+
+// print adds text to the line.
+func (line *statusLine) print(text string) {
+	if plainOutput {
+		line.pending += text
+		return
+	}
+	fmt.Print(text)
+}
+
+// finish ends the line with text in the given color.
+func (line *statusLine) finish(color, text string) {
+	fmt.Println(line.pending + colorize(color, text))
+	line.pending = ""
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// emailNotifier emails an address through an SMTP server whenever the state
+// of the transfer changes.
+type emailNotifier struct {
+	to     string
+	from   string
+	server string
+	auth   smtp.Auth
+}
+
+func newEmailNotifier(to string, options notifierOptions) (notifier, error) {
+	if to == "" {
+		return nil, errors.New("expected an address, e.g. email=you@example.com")
+	}
+	if options.smtpServer == "" {
+		return nil, errors.New("-smtp-server is needed to send email")
+	}
+	host, _, err := net.SplitHostPort(options.smtpServer)
+	if err != nil {
+		return nil, fmt.Errorf("-smtp-server %s: expected HOST:PORT, e.g. smtp.example.com:587", options.smtpServer)
+	}
+
+	email := &emailNotifier{to: to, from: options.smtpFrom, server: options.smtpServer}
+	if email.from == "" {
+		email.from = options.smtpUsername
+	}
+	if email.from == "" {
+		email.from = to
+	}
+	// Plain authentication is only used over TLS or to localhost
+	if options.smtpUsername != "" {
+		email.auth = smtp.PlainAuth("", options.smtpUsername, options.smtpPassword, host)
+	}
+	return onStateChange(email), nil
+}
+
+func (email *emailNotifier) notify(update progressUpdate) error {
+	summary := update.summary()
+	subject := summary
+	if update.State == "changed" {
+		// The changes list every channel, too many for a subject
+		subject = "Changes to the source account's subscriptions"
+	}
+	message := strings.Join([]string{
+		"From: " + email.from,
+		"To: " + email.to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		summary,
+		"",
+	}, "\r\n")
+	return smtp.SendMail(email.server, email.auth, email.from, []string{email.to}, []byte(message))
+}
+
+func (email *emailNotifier) close() {}
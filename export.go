@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// exportOptions are the export command's settings shared by all exporters,
+// each exporter uses the ones that apply to it.
+type exportOptions struct {
+	url        string
+	token      string
+	username   string
+	password   string
+	collection string
+	backup     string
+	output     string
+
+	// readState reads the state file, for exporters including the
+	// channels' transfer status, if not nil
+	readState func() (*importState, error)
+
+	// topics are the topics of each channel, set when grouping by topic
+	topics map[string][]string
+	// tags are the tags of each channel in the state file, such as the
+	// groups it was imported from
+	tags map[string][]string
+	// routes are the categories rules routed channels to
+	routes map[string]string
+}
+
+// category returns the collection or category a channel's feed is filed
+// under: where the rules routed it, its first tag in the state file, the
+// channel's first topic when grouping by topic, otherwise the chosen
+// collection.
+func (options exportOptions) category(channelID string) string {
+	if route := options.routes[channelID]; route != "" {
+		return route
+	}
+	if tags := options.tags[channelID]; len(tags) > 0 {
+		return tags[0]
+	}
+	if topics := options.topics[channelID]; len(topics) > 0 {
+		return topics[0]
+	}
+	return options.collection
+}
+
+// outputOr returns the file to export to, defaulting to name.
+func (options exportOptions) outputOr(name string) string {
+	if options.output == "" {
+		return name
+	}
+	return options.output
+}
+
+// exporters are the services subscriptions can be exported to with -to.
+var exporters = map[string]func(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error{
+	"csv":            exportToCSV,
+	"feedly":         exportToFeedly,
+	"freetube":       exportToFreeTube,
+	"freshrss":       exportToFreshRSS,
+	"grayjay":        exportToGrayjay,
+	"html":           exportToHTML,
+	"invidious":      exportToInvidious,
+	"invidious-file": exportToInvidiousFile,
+	"json":           exportToJSON,
+	"jsonfeed":       exportToJSONFeed,
+	"kodi":           exportToKodi,
+	"miniflux":       exportToMiniflux,
+	"newpipe":        exportToNewPipe,
+	"newpipe-backup": exportToNewPipeBackup,
+	"opml":           exportToOPML,
+	"piped":          exportToPiped,
+	"piped-file":     exportToPipedFile,
+	"sqlite":         exportToSQLite,
+}
+
+func exportCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	to := flags.String("to", "", "service or file format to export the source account's subscriptions to: "+strings.Join(exporterNames(), ", "))
+	options := exportOptions{}
+	flags.StringVar(&options.url, "url", "", "URL of the instance exported to, for self-hosted services")
+	flags.StringVar(&options.token, "token", "", "access token or API key for the service exported to")
+	flags.StringVar(&options.username, "username", "", "username for the service exported to")
+	flags.StringVar(&options.password, "password", "", "password for the service exported to")
+	flags.StringVar(&options.collection, "collection", "YouTube", "collection or category to add the channel feeds to")
+	flags.StringVar(&options.backup, "backup", "", "existing backup to add the subscriptions to, for backup file targets")
+	flags.StringVar(&options.output, "output", "", "file to write the export to, for file targets")
+	refresh := flags.Bool("refresh", false, "list the source subscriptions again instead of using the ones listed by an earlier command")
+	groupByTopic := flags.Bool("group-by-topic", false, "file each channel under a category named after its YouTube topic instead of -collection")
+	groupByTag := flags.Bool("group-by-tag", false, "file each channel under its first tag in the state file, such as the PocketTube group it was imported from")
+	fromState := flags.Bool("from-state", false, "export the channels in the state file, filed under their tags, instead of the source account's subscriptions")
+	rulesFile := flags.String("rules", "", "rules file deciding which channels to export and which category to route them to")
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		options.readState = func() (*importState, error) {
+			return readStateFromFile(stateFile)
+		}
+
+		export, ok := exporters[*to]
+		if !ok {
+			log.Fatalf("Unknown export target %q, expected one of: %s", *to, strings.Join(exporterNames(), ", "))
+		}
+
+		ctx := context.Background()
+
+		var service *youtube.Service
+		sourceService := func() *youtube.Service {
+			if service == nil {
+				service = getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}
+			return service
+		}
+		// Channels in the state file may have been imported from a file, with
+		// no source account, so they are looked up with the target account
+		lookupService := sourceService
+		if *fromState {
+			lookupService = func() *youtube.Service {
+				if service == nil {
+					service = getService(ctx, "target", youtube.YoutubeReadonlyScope)
+				}
+				return service
+			}
+		}
+
+		var subscriptions []*youtube.Subscription
+		if *fromState {
+			// The state file is only read when asked to, as an encrypted one
+			// asks for its passphrase
+			state, err := options.readState()
+			if err != nil {
+				log.Fatalf("Unable to read state file: %v", err)
+			}
+			for _, channelStatus := range state.Channels {
+				subscriptions = append(subscriptions, channelStatus.Channel)
+			}
+			options.tags = state.channelTags()
+			fmt.Printf("Exporting the %s channels in %s\n", formatCount(len(subscriptions)), stateFile)
+		} else {
+			var err error
+			subscriptions, err = sourceSubscriptions(ctx, sourceService, *refresh)
+			if err != nil && len(subscriptions) == 0 {
+				log.Fatalf("Unable to list source channels: %v", err)
+			} else if err != nil {
+				fmt.Printf("Unable to list all source channels, exporting the %v listed: %v\n", len(subscriptions), err)
+			}
+
+			if *groupByTag {
+				if state, err := options.readState(); err == nil {
+					options.tags = state.channelTags()
+				} else {
+					fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to read the state file, exporting without the channels' tags: %v", err)))
+				}
+			}
+		}
+
+		if *rulesFile != "" {
+			rules, err := readRules(*rulesFile)
+			if err != nil {
+				log.Fatalf("Unable to read rules %s: %v", *rulesFile, err)
+			}
+			decisions, err := applyRules(ctx, lookupService(), rules, subscriptions)
+			if err != nil {
+				log.Fatalf("Unable to look up channel details: %v", err)
+			}
+
+			subscriptions = keptChannels(decisions)
+			options.routes = make(map[string]string)
+			for _, decision := range decisions {
+				options.routes[decision.channel.Snippet.ResourceId.ChannelId] = decision.route
+			}
+		}
+
+		if *groupByTopic {
+			fmt.Println("Fetching channel topics")
+			channelIDs := make([]string, 0, len(subscriptions))
+			for _, subscription := range subscriptions {
+				channelIDs = append(channelIDs, subscription.Snippet.ResourceId.ChannelId)
+			}
+			var err error
+			if options.topics, err = channelTopics(ctx, lookupService(), channelIDs); err != nil {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to fetch channel topics, exporting every channel to %s instead: %v", options.collection, err)))
+			}
+		}
+
+		if err := export(ctx, subscriptions, options); err != nil {
+			log.Fatalf("Unable to export to %s: %v", *to, err)
+		}
+	}
+}
+
+func exporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeExport creates the file and writes the export to it with write.
+func writeExport(file string, write func(f *os.File) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote subscriptions to %s\n", file)
+	return nil
+}
+
+// channelURL returns the address of a channel's page.
+func channelURL(channelID string) string {
+	return "https://www.youtube.com/channel/" + channelID
+}
+
+// channelFeedURL returns the RSS feed of a channel's uploads.
+func channelFeedURL(channelID string) string {
+	return "https://www.youtube.com/feeds/videos.xml?channel_id=" + channelID
+}
+
+// jsonRequest sends body encoded as JSON, if not nil, and decodes the JSON
+// response into result, if not nil. Responses other than 2xx are errors.
+func jsonRequest(ctx context.Context, method, url string, header http.Header, body, result interface{}) error {
+	var requestBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&requestBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, url, &requestBody)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, url, response.Status, strings.TrimSpace(string(message)))
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+const feedlyAPI = "https://cloud.feedly.com/v3"
+
+type feedlyCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type feedlySubscription struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	Categories []feedlyCategory `json:"categories"`
+}
+
+// exportToFeedly subscribes the Feedly account the token belongs to to each
+// channel's RSS feed, filed under its collection.
+func exportToFeedly(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	if options.token == "" {
+		return errors.New("a Feedly access token is required, pass it with -token")
+	}
+
+	header := http.Header{"Authorization": {"OAuth " + options.token}}
+
+	var profile struct {
+		ID string `json:"id"`
+	}
+	if err := jsonRequest(ctx, http.MethodGet, feedlyAPI+"/profile", header, nil, &profile); err != nil {
+		return err
+	}
+
+	for index, subscription := range subscriptions {
+		channelID := subscription.Snippet.ResourceId.ChannelId
+		label := options.category(channelID)
+
+		fmt.Printf("Adding feed %s: %s: ", channelNumber(index, len(subscriptions)), displayTitle(subscription.Snippet.Title, titleWidth))
+
+		category := feedlyCategory{
+			ID:    "user/" + profile.ID + "/category/" + label,
+			Label: label,
+		}
+		feed := feedlySubscription{
+			ID:         "feed/" + channelFeedURL(channelID),
+			Title:      subscription.Snippet.Title,
+			Categories: []feedlyCategory{category},
+		}
+		if err := jsonRequest(ctx, http.MethodPost, feedlyAPI+"/subscriptions", header, feed, nil); err != nil {
+			fmt.Printf("failed: %v\n", err)
+			continue
+		}
+
+		fmt.Printf("added to %s\n", label)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// freeTubeAllChannels is the ID of the FreeTube profile every subscription
+// is in.
+const freeTubeAllChannels = "allChannels"
+
+// freeTubeProfile is a FreeTube profile. FreeTube exports its subscriptions
+// as its profiles database, a profile per line.
+type freeTubeProfile struct {
+	Name          string                 `json:"name"`
+	BGColor       string                 `json:"bgColor"`
+	TextColor     string                 `json:"textColor"`
+	Subscriptions []freeTubeSubscription `json:"subscriptions"`
+	ID            string                 `json:"_id"`
+}
+
+type freeTubeSubscription struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+// readFreeTube finds the channels in a FreeTube subscriptions export, in any
+// of its profiles.
+func readFreeTube(file string, options importOptions) ([]channelReference, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var references []channelReference
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var profile freeTubeProfile
+		if err := json.Unmarshal([]byte(line), &profile); err != nil {
+			return nil, err
+		}
+		for _, subscription := range profile.Subscriptions {
+			if seen[subscription.ID] || !channelIDPattern.MatchString(subscription.ID) {
+				continue
+			}
+			seen[subscription.ID] = true
+			references = append(references, channelReference{id: subscription.ID, title: subscription.Name})
+		}
+	}
+	return references, scanner.Err()
+}
+
+// exportToFreeTube writes the channels as a FreeTube subscriptions export
+// holding the profile of all channels, ready to be imported in FreeTube's
+// data settings.
+func exportToFreeTube(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	profile := freeTubeProfile{
+		Name:          "All Channels",
+		BGColor:       "#000000",
+		TextColor:     "#FFFFFF",
+		Subscriptions: make([]freeTubeSubscription, 0, len(subscriptions)),
+		ID:            freeTubeAllChannels,
+	}
+	for _, subscription := range subscriptions {
+		snippet := subscription.Snippet
+		channel := freeTubeSubscription{ID: snippet.ResourceId.ChannelId, Name: snippet.Title}
+		if snippet.Thumbnails != nil && snippet.Thumbnails.Default != nil {
+			channel.Thumbnail = snippet.Thumbnails.Default.Url
+		}
+		profile.Subscriptions = append(profile.Subscriptions, channel)
+	}
+
+	return writeExport(options.outputOr("freetube-subscriptions.db"), func(f *os.File) error {
+		return json.NewEncoder(f).Encode(profile)
+	})
+}
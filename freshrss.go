@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// freshRSSClient talks to the Google Reader compatible API of a FreshRSS
+// instance.
+type freshRSSClient struct {
+	api  string
+	auth string
+}
+
+// exportToFreshRSS subscribes a FreshRSS account to each channel's RSS feed,
+// filing them under a label per category. The password is the account's API
+// password set in FreshRSS's profile settings.
+func exportToFreshRSS(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	if options.url == "" || options.username == "" || options.password == "" {
+		return errors.New("the FreshRSS instance URL, username and API password are required, pass them with -url, -username and -password")
+	}
+
+	client := &freshRSSClient{api: strings.TrimSuffix(options.url, "/") + "/api/greader.php"}
+	if err := client.login(ctx, options.username, options.password); err != nil {
+		return err
+	}
+
+	token, err := client.request(ctx, http.MethodGet, "/reader/api/0/token", nil)
+	if err != nil {
+		return err
+	}
+
+	for index, subscription := range subscriptions {
+		channelID := subscription.Snippet.ResourceId.ChannelId
+		category := options.category(channelID)
+
+		fmt.Printf("Adding feed %s: %s: ", channelNumber(index, len(subscriptions)), displayTitle(subscription.Snippet.Title, titleWidth))
+
+		form := url.Values{
+			"ac": {"subscribe"},
+			"s":  {"feed/" + channelFeedURL(channelID)},
+			"t":  {subscription.Snippet.Title},
+			"a":  {"user/-/label/" + category},
+			"T":  {strings.TrimSpace(token)},
+		}
+		if _, err := client.request(ctx, http.MethodPost, "/reader/api/0/subscription/edit", form); err != nil {
+			fmt.Printf("failed: %v\n", err)
+			continue
+		}
+
+		fmt.Printf("added to %s\n", category)
+	}
+
+	return nil
+}
+
+func (client *freshRSSClient) login(ctx context.Context, username, password string) error {
+	response, err := client.request(ctx, http.MethodPost, "/accounts/ClientLogin", url.Values{
+		"Email":  {username},
+		"Passwd": {password},
+	})
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		if auth := strings.TrimPrefix(scanner.Text(), "Auth="); auth != scanner.Text() {
+			client.auth = auth
+			return nil
+		}
+	}
+	return errors.New("FreshRSS login response did not contain an auth token")
+}
+
+// request sends form, if not nil, to the API and returns the response body.
+func (client *freshRSSClient) request(ctx context.Context, method, path string, form url.Values) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, method, client.api+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if client.auth != "" {
+		request.Header.Set("Authorization", "GoogleLogin auth="+client.auth)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", fmt.Errorf("%s %s: %s: %s", method, path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
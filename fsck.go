@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// fsckCommand compares the state file against the target account's actual
+// subscriptions and fixes channels marked imported that aren't subscribed to,
+// and channels still pending that already are.
+func fsckCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	channelMapFile := flags.String("channel-map", "", "channel map file used for the transfer")
+	dryRun := flags.Bool("dry-run", false, "only report discrepancies, don't fix them")
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		channelMap := make(map[string]string)
+		if *channelMapFile != "" {
+			var err error
+			if channelMap, err = readChannelMap(*channelMapFile); err != nil {
+				log.Fatalf("Unable to read channel map file: %v", err)
+			}
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		ctx := context.Background()
+		targetService := getService(ctx, "target", youtube.YoutubeForceSslScope)
+
+		fmt.Println("Fetching target account subscriptions")
+		subscribed, err := subscribedChannels(ctx, targetService, nil)
+		if err != nil {
+			log.Fatalf("Unable to list target channels: %v", err)
+		}
+
+		discrepancies := 0
+		for index, channelStatus := range state.Channels {
+			channel := channelStatus.Channel
+			channelID := channel.Snippet.ResourceId.ChannelId
+			if newChannelID, ok := channelMap[channelID]; ok {
+				channelID = newChannelID
+			}
+
+			switch {
+			case channelStatus.Imported && !subscribed[channelID]:
+				fmt.Printf("%s (%s): marked imported, but not subscribed to, marking pending\n", channel.Snippet.Title, channelID)
+				state.Channels[index].Imported = false
+			case !channelStatus.Imported && subscribed[channelID]:
+				fmt.Printf("%s (%s): pending, but already subscribed to, marking imported\n", channel.Snippet.Title, channelID)
+				state.Channels[index].Imported = true
+			default:
+				continue
+			}
+			discrepancies++
+		}
+
+		fmt.Printf("Found %s discrepancies between the state file and the %s subscriptions of the target account\n", formatCount(discrepancies), formatCount(len(subscribed)))
+
+		if discrepancies == 0 || *dryRun {
+			return
+		}
+		if err := writeStateToFile(stateFile, state); err != nil {
+			log.Fatalf("Unable to save state: %v", err)
+		}
+	}
+}
+
+// subscribedChannels returns the IDs of the channels the account is
+// subscribed to. spend, if not nil, is called with the quota of each
+// request before it is made, like for listSubscriptionsSpending.
+func subscribedChannels(ctx context.Context, service *youtube.Service, spend func(cost int) error) (map[string]bool, error) {
+	subscriptions, err := listSubscriptionsSpending(ctx, service, "", []string{"snippet"}, spend)
+	if err != nil {
+		return nil, err
+	}
+	subscribed := make(map[string]bool)
+	for _, subscription := range subscriptions {
+		subscribed[subscription.Snippet.ResourceId.ChannelId] = true
+	}
+	return subscribed, nil
+}
+
+// markSubscribedImported marks the pending channels the target account is
+// already subscribed to imported, so they don't cost an insert each only to
+// fail as duplicates, and returns how many it marked.
+func markSubscribedImported(state *importState, subscribed map[string]bool, channelMap map[string]string) int {
+	marked := 0
+	for index, channelStatus := range state.Channels {
+		channelID := channelStatus.Channel.Snippet.ResourceId.ChannelId
+		if newChannelID, ok := channelMap[channelID]; ok {
+			channelID = newChannelID
+		}
+		if !channelStatus.Imported && subscribed[channelID] {
+			state.Channels[index].Imported = true
+			state.Channels[index].Unavailable = false
+			state.Channels[index].Failure = nil
+			marked++
+		}
+	}
+	return marked
+}
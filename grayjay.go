@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// grayjaySubscriptionsStore is the file in a Grayjay backup zip listing the
+// subscriptions, as a JSON array of channel links on any platform.
+const grayjaySubscriptionsStore = "stores/subscriptions"
+
+// readGrayjay finds the YouTube channels in a Grayjay backup zip, or in its
+// subscriptions store on its own, leaving out the other platforms'.
+func readGrayjay(file string, options importOptions) ([]channelReference, error) {
+	var links []string
+	if archive, err := zip.OpenReader(file); err == nil {
+		defer archive.Close()
+		store, err := archive.Open(grayjaySubscriptionsStore)
+		if err != nil {
+			return nil, fmt.Errorf("%s has no %s, is it a Grayjay backup? %v", file, grayjaySubscriptionsStore, err)
+		}
+		defer store.Close()
+		if err := json.NewDecoder(store).Decode(&links); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &links); err != nil {
+			return nil, err
+		}
+	}
+
+	var references []channelReference
+	for _, link := range links {
+		if reference, ok := parseChannelURL(link); ok {
+			references = append(references, reference)
+		}
+	}
+	return references, nil
+}
+
+// exportToGrayjay writes the channels as a Grayjay backup zip holding only
+// the subscriptions, ready to be imported in Grayjay, which adds them to the
+// ones it has.
+func exportToGrayjay(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	links := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		links = append(links, channelURL(subscription.Snippet.ResourceId.ChannelId))
+	}
+
+	return writeExport(options.outputOr("grayjay-subscriptions.zip"), func(f *os.File) error {
+		archive := zip.NewWriter(f)
+		for _, entry := range []struct {
+			name    string
+			content interface{}
+		}{
+			{"exportInfo", map[string]string{"version": "1"}},
+			{grayjaySubscriptionsStore, links},
+		} {
+			w, err := archive.Create(entry.name)
+			if err != nil {
+				return err
+			}
+			if err := json.NewEncoder(w).Encode(entry.content); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// historyCommand lists the runs recorded in the state file, or with
+// "show N" the details of the Nth run, so a transfer stretching across weeks
+// can be followed.
+func historyCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	addStateFileFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s history [FLAGS] [show RUN]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		args = flags.Args()
+
+		usage := func() {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			fmt.Println("No runs yet")
+			return
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		switch {
+		case len(args) == 0:
+			if len(state.Runs) == 0 {
+				fmt.Println("No runs yet")
+			}
+			for index, run := range state.Runs {
+				fmt.Printf("%3d  %v\n", index+1, run)
+			}
+		case len(args) == 2 && args[0] == "show":
+			number, err := strconv.Atoi(args[1])
+			if err != nil || number < 1 || number > len(state.Runs) {
+				log.Fatalf("No run %s, expected a number from 1 to %v", args[1], len(state.Runs))
+			}
+			printRun(state.Runs[number-1])
+		default:
+			usage()
+		}
+	}
+}
+
+func printRun(run RunRecord) {
+	if run.Label != "" {
+		fmt.Printf("Label:     %s\n", run.Label)
+	}
+	fmt.Printf("Started:   %s\n", formatDateTime(run.Started))
+	fmt.Printf("Finished:  %s (took %v)\n", formatDateTime(run.Finished), run.Finished.Sub(run.Started).Round(time.Second))
+	fmt.Printf("Imported:  %s\n", formatCount(run.Imported))
+	fmt.Printf("Failed:    %s\n", formatCount(run.Failed))
+	fmt.Printf("Quota:     %s units", formatCount(run.QuotaUsed))
+	if run.QuotaExceeded {
+		fmt.Print(", exceeded")
+	}
+	fmt.Println()
+
+	if len(run.Unavailable) > 0 {
+		fmt.Println("Unavailable to the target account:")
+		for _, channel := range run.Unavailable {
+			fmt.Printf("  %s\n", channel)
+		}
+	}
+
+	if len(run.Errors) > 0 {
+		fmt.Println("Errors:")
+		for _, message := range run.Errors {
+			fmt.Printf("  %s\n", message)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// htmlReport is what the HTML report shows.
+type htmlReport struct {
+	Generated time.Time
+	Channels  []htmlReportChannel
+}
+
+type htmlReportChannel struct {
+	Title, Description, URL, Thumbnail string
+	// Subscribed is when the source account subscribed, if known
+	Subscribed string
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>YouTube subscriptions</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+ul { list-style: none; padding: 0; }
+li { display: flex; gap: 1em; margin-bottom: 1.5em; }
+img { width: 88px; height: 88px; border-radius: 50%; flex-shrink: 0; }
+h2 { margin: 0 0 0.2em; font-size: 1.1em; }
+p { margin: 0.2em 0; white-space: pre-line; }
+.subscribed { color: #666; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{len .Channels}} YouTube subscriptions</h1>
+<p class="subscribed">As of {{.Generated.Format "2006-01-02 15:04"}}</p>
+<ul>{{range .Channels}}
+<li>{{if .Thumbnail}}<a href="{{.URL}}"><img src="{{.Thumbnail}}" alt="" loading="lazy"></a>{{end}}
+<div>
+<h2><a href="{{.URL}}">{{.Title}}</a></h2>{{if .Subscribed}}
+<p class="subscribed">Subscribed {{.Subscribed}}</p>{{end}}{{if .Description}}
+<p>{{.Description}}</p>{{end}}
+</div>
+</li>{{end}}
+</ul>
+</body>
+</html>
+`))
+
+// exportToHTML writes a standalone HTML page listing the channels with
+// their thumbnails, descriptions and links, as a backup to read.
+func exportToHTML(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	report := htmlReport{Generated: time.Now()}
+	for _, subscription := range subscriptions {
+		snippet := subscription.Snippet
+		channel := htmlReportChannel{
+			Title:       snippet.Title,
+			Description: snippet.Description,
+			URL:         channelURL(snippet.ResourceId.ChannelId),
+		}
+		if snippet.Thumbnails != nil && snippet.Thumbnails.Medium != nil {
+			channel.Thumbnail = snippet.Thumbnails.Medium.Url
+		} else if snippet.Thumbnails != nil && snippet.Thumbnails.Default != nil {
+			channel.Thumbnail = snippet.Thumbnails.Default.Url
+		}
+		if published, err := time.Parse(time.RFC3339, snippet.PublishedAt); err == nil {
+			channel.Subscribed = formatDate(published)
+		}
+		report.Channels = append(report.Channels, channel)
+	}
+
+	return writeExport(options.outputOr("subscriptions.html"), func(f *os.File) error {
+		return htmlReportTemplate.Execute(f, report)
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// importOptions are the import command's settings shared by all importers,
+// each importer uses the ones that apply to it.
+type importOptions struct {
+	// mapping says where the channel is in each record, set with -map
+	mapping []fieldMapping
+}
+
+// importers read the channels in a file, for import -from.
+var importers = map[string]func(file string, options importOptions) ([]channelReference, error){
+	"bookmarks":   readBookmarks,
+	"csv":         readCSV,
+	"freetube":    readFreeTube,
+	"grayjay":     readGrayjay,
+	"invidious":   readInvidious,
+	"json":        readJSON,
+	"newpipe":     readNewPipe,
+	"opml":        readOPML,
+	"piped":       readPiped,
+	"pockettube":  readPocketTube,
+	"takeout":     readTakeoutZip,
+	"takeout-csv": readTakeoutCSV,
+	"text":        readText,
+}
+
+// importCommand adds the channels found in a file to the state file as
+// pending, so the next transfer subscribes the target account to them
+// without needing access to a source account.
+func importCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	from := flags.String("from", "", "format of the file to import: "+strings.Join(importerNames(), ", "))
+	var mappings repeatedFlag
+	flags.Var(&mappings, "map", "where a field is in each CSV or JSON record, FIELD=col:N or FIELD=NAME for a column or JSON key, can be repeated: "+strings.Join(importFields, ", "))
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import -from FORMAT FILE\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		read, ok := importers[*from]
+		if !ok || flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		options := importOptions{}
+		for _, value := range mappings {
+			mapping, err := parseFieldMapping(value)
+			if err != nil {
+				log.Fatalf("Invalid -map: %v", err)
+			}
+			options.mapping = append(options.mapping, mapping)
+		}
+
+		references, err := read(flags.Arg(0), options)
+		if err != nil {
+			log.Fatalf("Unable to read %s: %v", flags.Arg(0), err)
+		}
+		fmt.Printf("Found %s channels in %s\n", formatCount(len(references)), flags.Arg(0))
+
+		ctx := context.Background()
+		targetService := getService(ctx, "target", youtube.YoutubeForceSslScope)
+
+		channels, unresolved, err := resolveChannels(ctx, targetService, references)
+		if err != nil {
+			log.Fatalf("Unable to look up channels: %v", err)
+		}
+		for _, reference := range unresolved {
+			fmt.Printf("Unable to find channel %v %s\n", reference, reference.title)
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			state = &importState{}
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		added := state.addChannels(channels)
+		// Only references by ID are known to be the channel resolved
+		tags := make(map[string][]string)
+		for _, reference := range references {
+			if reference.id != "" && len(reference.tags) > 0 {
+				tags[reference.id] = append(tags[reference.id], reference.tags...)
+			}
+		}
+		tagged := state.tagChannels(tags)
+		if err := writeStateToFile(stateFile, state); err != nil {
+			log.Fatalf("Unable to save state: %v", err)
+		}
+
+		fmt.Printf("Added %s new channels, run the transfer to subscribe the target account to them\n", formatCount(added))
+		if tagged > 0 {
+			fmt.Printf("Tagged %s channels with the groups they are in\n", formatCount(tagged))
+		}
+	}
+}
+
+func importerNames() []string {
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// exportToInvidious subscribes an Invidious account to each channel through
+// the instance's API. The token needs the subscriptions scopes, it can be
+// created on the instance's /authorize_token page.
+func exportToInvidious(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	if options.url == "" || options.token == "" {
+		return errors.New("the Invidious instance URL and an API token are required, pass them with -url and -token")
+	}
+
+	api := strings.TrimSuffix(options.url, "/") + "/api/v1/auth/subscriptions"
+	header := http.Header{"Authorization": {"Bearer " + options.token}}
+
+	var existing []struct {
+		AuthorID string `json:"authorId"`
+	}
+	if err := jsonRequest(ctx, http.MethodGet, api, header, nil, &existing); err != nil {
+		return err
+	}
+	subscribed := make(map[string]bool)
+	for _, channel := range existing {
+		subscribed[channel.AuthorID] = true
+	}
+
+	for index, subscription := range subscriptions {
+		channelID := subscription.Snippet.ResourceId.ChannelId
+
+		fmt.Printf("Subscribing to channel %s: %s: ", channelNumber(index, len(subscriptions)), displayTitle(subscription.Snippet.Title, titleWidth))
+
+		if subscribed[channelID] {
+			fmt.Printf("already subscribed, skipping\n")
+			continue
+		}
+
+		if err := jsonRequest(ctx, http.MethodPost, api+"/"+channelID, header, nil, nil); err != nil {
+			fmt.Printf("failed: %v\n", err)
+			continue
+		}
+
+		fmt.Printf("subscribed\n")
+	}
+
+	return nil
+}
+
+// invidiousData is the JSON an Invidious account's data is exported to and
+// imported from in its import/export settings. Only the subscriptions, the
+// channel IDs, are read and written, the rest is optional on import.
+type invidiousData struct {
+	Subscriptions []string `json:"subscriptions"`
+}
+
+// readInvidious finds the channels in an Invidious data export.
+func readInvidious(file string, options importOptions) ([]channelReference, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var export invidiousData
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	var references []channelReference
+	for _, channelID := range export.Subscriptions {
+		if channelIDPattern.MatchString(channelID) {
+			references = append(references, channelReference{id: channelID})
+		}
+	}
+	return references, nil
+}
+
+// exportToInvidiousFile writes the channels as an Invidious data export,
+// ready to be imported in an instance's import/export settings without an
+// API token.
+func exportToInvidiousFile(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	export := invidiousData{Subscriptions: make([]string, 0, len(subscriptions))}
+	for _, subscription := range subscriptions {
+		export.Subscriptions = append(export.Subscriptions, subscription.Snippet.ResourceId.ChannelId)
+	}
+
+	return writeExport(options.outputOr("subscription_manager.json"), func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	})
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// exportToJSON writes the subscriptions as the API returned them, snippet,
+// content details and all, as a pretty-printed JSON array for scripts to
+// take whatever they need from.
+func exportToJSON(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	return writeExport(options.outputOr("subscriptions-full.json"), func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(subscriptions)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// jsonFeed is a JSON Feed (https://jsonfeed.org/version/1.1) document with
+// an item per channel, pointing at the channel's RSS feed.
+type jsonFeed struct {
+	Version string         `json:"version"`
+	Title   string         `json:"title"`
+	Items   []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string          `json:"id"`
+	URL           string          `json:"url"`
+	Title         string          `json:"title"`
+	ContentText   string          `json:"content_text"`
+	Image         string          `json:"image,omitempty"`
+	DatePublished string          `json:"date_published,omitempty"`
+	YouTube       jsonFeedYouTube `json:"_youtube"`
+}
+
+// jsonFeedYouTube is the JSON Feed extension carrying each channel's feed.
+type jsonFeedYouTube struct {
+	About     string `json:"about"`
+	ChannelID string `json:"channel_id"`
+	FeedURL   string `json:"feed_url"`
+}
+
+// exportToJSONFeed writes the channels as a JSON Feed, with the subscription
+// date as each item's publish date.
+func exportToJSONFeed(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	feed := jsonFeed{
+		Version: "https://jsonfeed.org/version/1.1",
+		Title:   "YouTube subscriptions",
+		Items:   make([]jsonFeedItem, 0, len(subscriptions)),
+	}
+
+	for _, subscription := range subscriptions {
+		snippet := subscription.Snippet
+		channelID := snippet.ResourceId.ChannelId
+
+		item := jsonFeedItem{
+			ID:            channelID,
+			URL:           channelURL(channelID),
+			Title:         snippet.Title,
+			ContentText:   snippet.Description,
+			DatePublished: snippet.PublishedAt,
+			YouTube: jsonFeedYouTube{
+				About:     "https://github.com/martinbjeldbak/youtube-subscriptions-transfer",
+				ChannelID: channelID,
+				FeedURL:   channelFeedURL(channelID),
+			},
+		}
+		if snippet.Thumbnails != nil && snippet.Thumbnails.High != nil {
+			item.Image = snippet.Thumbnails.High.Url
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return writeExport(options.outputOr("subscriptions.json"), func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(feed)
+	})
+}
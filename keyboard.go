@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// keyboardControls reads key presses from the terminal while channels are
+// being imported: p pauses, r resumes and s skips the current channel.
+type keyboardControls struct {
+	keys     chan byte
+	restore  func()
+	stopOnce sync.Once
+}
+
+// startKeyboardControls puts the terminal into a mode where single key
+// presses can be read. It returns nil if stdin is not a terminal, in which
+// case no controls are available.
+func startKeyboardControls() *keyboardControls {
+	restore, err := enableKeyPresses(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil
+	}
+
+	controls := &keyboardControls{keys: make(chan byte, 16), restore: restore}
+
+	go func() {
+		key := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(key); err != nil {
+				close(controls.keys)
+				return
+			}
+			controls.keys <- key[0]
+		}
+	}()
+
+	fmt.Println("Press p to pause, r to resume and s to skip the current channel")
+
+	return controls
+}
+
+// stop restores the terminal. It can be called more than once, such as
+// both on Ctrl-C and when the transfer returns.
+func (controls *keyboardControls) stop() {
+	if controls != nil {
+		controls.stopOnce.Do(controls.restore)
+	}
+}
+
+// shouldSkip handles the keys pressed since it was last called, blocking
+// while paused until resumed or ctx is done. onPause is called when pausing.
+// It reports whether the current channel should be skipped, so it is called
+// right before subscribing to it.
+func (controls *keyboardControls) shouldSkip(ctx context.Context, onPause func()) bool {
+	if controls == nil {
+		return false
+	}
+
+	for {
+		select {
+		case key, ok := <-controls.keys:
+			if !ok {
+				return false
+			}
+			switch key {
+			case 's':
+				return true
+			case 'p':
+				onPause()
+				return controls.waitForResume(ctx)
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func (controls *keyboardControls) waitForResume(ctx context.Context) bool {
+	fmt.Println("Paused, press r to resume or s to skip the current channel")
+	for {
+		select {
+		case key, ok := <-controls.keys:
+			if !ok {
+				return false
+			}
+			switch key {
+			case 'r':
+				// The channel's status line carries on after this
+				fmt.Print("Resumed: ")
+				return false
+			case 's':
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!windows
+
+package main
+
+import "errors"
+
+func enableKeyPresses(fd int) (func(), error) {
+	return nil, errors.New("key presses are not supported on this platform")
+}
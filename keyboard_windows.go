@@ -0,0 +1,22 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// enableKeyPresses switches the console on fd to deliver key presses as they
+// are typed, without echoing them. Ctrl-C keeps working. It returns a
+// function restoring the previous console mode.
+func enableKeyPresses(fd int) (func(), error) {
+	console := windows.Handle(fd)
+
+	var mode uint32
+	if err := windows.GetConsoleMode(console, &mode); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(console, mode&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT)); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		windows.SetConsoleMode(console, mode)
+	}, nil
+}
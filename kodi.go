@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// kodiFavourites is Kodi's favourites.xml, found in the userdata folder.
+type kodiFavourites struct {
+	XMLName    xml.Name        `xml:"favourites"`
+	Favourites []kodiFavourite `xml:"favourite"`
+}
+
+type kodiFavourite struct {
+	Name   string `xml:"name,attr"`
+	Thumb  string `xml:"thumb,attr,omitempty"`
+	Action string `xml:",chardata"`
+}
+
+// exportToKodi writes a Kodi favourites file with an entry per channel that
+// opens the channel in the Kodi YouTube add-on.
+func exportToKodi(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	favourites := kodiFavourites{}
+
+	for _, subscription := range subscriptions {
+		snippet := subscription.Snippet
+
+		favourite := kodiFavourite{
+			Name:   snippet.Title,
+			Action: fmt.Sprintf("ActivateWindow(Videos,plugin://plugin.video.youtube/channel/%s/,return)", snippet.ResourceId.ChannelId),
+		}
+		if snippet.Thumbnails != nil && snippet.Thumbnails.Default != nil {
+			favourite.Thumb = snippet.Thumbnails.Default.Url
+		}
+
+		favourites.Favourites = append(favourites.Favourites, favourite)
+	}
+
+	return writeExport(options.outputOr("favourites.xml"), func(f *os.File) error {
+		if _, err := f.WriteString(xml.Header); err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(f)
+		encoder.Indent("", "    ")
+		return encoder.Encode(favourites)
+	})
+}
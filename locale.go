@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// localePrinter formats numbers in summaries the way the user's locale
+// does, e.g. 1,234,567 in English and 1.234.567 in Danish.
+var localePrinter = message.NewPrinter(userLocale("LC_NUMERIC"))
+
+// dateLayout is how the user's locale writes dates.
+var dateLayout = localeDateLayout(userLocale("LC_TIME"))
+
+// userLocale returns the locale set for category, such as LC_TIME, in the
+// environment: LC_ALL, then the category, then LANG, English if none is set.
+func userLocale(category string) language.Tag {
+	for _, variable := range []string{"LC_ALL", category, "LANG"} {
+		value := os.Getenv(variable)
+		// e.g. da_DK.UTF-8 or de_DE@euro
+		value = strings.SplitN(strings.SplitN(value, ".", 2)[0], "@", 2)[0]
+		if value == "" || value == "C" || value == "POSIX" {
+			continue
+		}
+		if tag, err := language.Parse(strings.ReplaceAll(value, "_", "-")); err == nil {
+			return tag
+		}
+	}
+	return language.English
+}
+
+// localeDateLayout returns the date layout used in the locale's region:
+// month first in the US, day first with dots in much of Europe, day first
+// with slashes in other countries writing the day first, ISO 8601 elsewhere
+// or when the locale has no region.
+func localeDateLayout(tag language.Tag) string {
+	region, confidence := tag.Region()
+	if confidence != language.Exact {
+		return "2006-01-02"
+	}
+	switch region.String() {
+	case "US", "PH":
+		return "01/02/2006"
+	case "DE", "AT", "CH", "DK", "NO", "FI", "PL", "CZ", "SK", "RU", "UA", "TR", "RO", "HU":
+		return "02.01.2006"
+	case "GB", "IE", "FR", "BE", "ES", "IT", "PT", "BR", "AU", "NZ", "IN", "GR", "MX", "AR":
+		return "02/01/2006"
+	default:
+		return "2006-01-02"
+	}
+}
+
+// formatCount formats a count with the locale's digit grouping.
+func formatCount(count interface{}) string {
+	return localePrinter.Sprintf("%d", count)
+}
+
+// formatDate formats a date the locale's way.
+func formatDate(t time.Time) string {
+	return t.Format(dateLayout)
+}
+
+// formatDateTime formats a date the locale's way followed by the time.
+func formatDateTime(t time.Time) string {
+	return t.Format(dateLayout + " 15:04")
+}
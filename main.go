@@ -1,21 +1,25 @@
 package main
 
 import (
-	"encoding/gob"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/term"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 )
@@ -29,30 +33,16 @@ func handleError(err error, message string) {
 	}
 }
 
-func mySubscriptions(context context.Context, service *youtube.Service, parts []string) ([]*youtube.Subscription, error) {
-	call := service.Subscriptions.List(parts)
-	call.Mine(true)
-
-	var channels = make([]*youtube.Subscription, 0)
-
-	err := call.Pages(context, func(slr *youtube.SubscriptionListResponse) error {
-		channels = append(channels, slr.Items...)
-
-		return nil
-	})
-	return channels, err
-}
-
 // saveToken uses a file path to create a file and store the
 // token in it.
 func saveToken(file string, token *oauth2.Token) {
 	fmt.Printf("Saving credential file to: %s\n", file)
-	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	err := writeFileAtomically(file, 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(token)
+	})
 	if err != nil {
 		log.Fatalf("Unable to cache oauth token: %v", err)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
 }
 
 // tokenFromFile retrieves a Token from a given file path.
@@ -68,177 +58,688 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 	return t, err
 }
 
-// getTokenFromWeb uses Config to request a Token.
-// It returns the retrieved Token.
-func getTokenFromWeb(ctx context.Context, config *oauth2.Config, name string) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf(name+" account: Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
+// tokenCacheDir creates the directory credentials are cached in, if needed.
+// It returns the directory's path.
+func tokenCacheDir() (string, error) {
+	tokenCacheDir, err := tokenCacheDirPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(tokenCacheDir, 0700); err != nil {
+		return "", err
+	}
+	if settings.CredentialsDir == "" {
+		migrateTokenCache(tokenCacheDir)
+	}
+	return tokenCacheDir, nil
+}
 
-	var code string
-	if _, err := fmt.Scan(&code); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
+// tokenCacheDirPath returns the directory credentials are cached in like
+// tokenCacheDir, without creating it, for only reading from it.
+func tokenCacheDirPath() (string, error) {
+	if settings.CredentialsDir != "" {
+		return expandHome(settings.CredentialsDir), nil
 	}
 
-	tok, err := config.Exchange(ctx, code)
+	configDir, err := os.UserConfigDir()
 	if err != nil {
-		log.Fatalf("Unable to retrieve token from web %v", err)
+		return "", err
+	}
+	return filepath.Join(configDir, "youtube-subscriptions-transfer", "credentials"), nil
+}
+
+// migrateTokenCache moves credentials cached in ~/.credentials, where they
+// were kept before, to the token cache directory. Other files there are
+// left alone, as the directory may be shared with other tools.
+func migrateTokenCache(tokenCacheDir string) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	oldDir := filepath.Join(homeDir, ".credentials")
+
+	for _, name := range authAccounts {
+		file := url.QueryEscape(name + ".json")
+		oldFile, newFile := filepath.Join(oldDir, file), filepath.Join(tokenCacheDir, file)
+		if _, err := os.Stat(oldFile); err != nil {
+			continue
+		}
+		if _, err := os.Stat(newFile); err == nil {
+			continue
+		}
+		if err := os.Rename(oldFile, newFile); err != nil {
+			fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to move %s to %s: %v", oldFile, newFile, err)))
+			continue
+		}
+		fmt.Printf("Moved cached credentials %s to %s\n", oldFile, newFile)
 	}
-	return tok
 }
 
 // tokenCacheFile generates credential file path/filename.
 // It returns the generated credential path/filename.
 func tokenCacheFile(name string) (string, error) {
-	usr, err := user.Current()
+	tokenCacheDir, err := tokenCacheDir()
 	if err != nil {
 		return "", err
 	}
-	tokenCacheDir := filepath.Join(usr.HomeDir, ".credentials")
-	os.MkdirAll(tokenCacheDir, 0700)
-	return filepath.Join(tokenCacheDir,
-		url.QueryEscape(name+".json")), err
+	return tokenFileIn(tokenCacheDir, name), nil
+}
+
+// tokenFileIn returns the file the credentials named name are cached in
+// within the directory.
+func tokenFileIn(dir, name string) string {
+	return filepath.Join(dir, url.QueryEscape(name+".json"))
 }
 
 // getClient uses a Context and Config to retrieve a Token
 // then generate a Client. It returns the generated Client.
 func getClient(ctx context.Context, config *oauth2.Config, name string) *http.Client {
-	cacheFile, err := tokenCacheFile(name)
-	if err != nil {
-		log.Fatalf("Unable to get path to cached credential file. %v", err)
+	authorize := func() *oauth2.Token {
+		var tok *oauth2.Token
+		if deviceAuth {
+			tok = getTokenFromDevice(ctx, config, name)
+		} else {
+			tok = getTokenFromWeb(ctx, config, name)
+		}
+		storeToken(name, tok)
+		return tok
 	}
-	tok, err := tokenFromFile(cacheFile)
+
+	tok, err := loadToken(name)
 	if err != nil {
-		tok = getTokenFromWeb(ctx, config, name)
-		saveToken(cacheFile, tok)
+		tok = authorize()
 	}
-	return config.Client(ctx, tok)
+	return newAuthorizedClient(ctx, config, tok, func() (*oauth2.Token, error) {
+		// Without anyone to authorize it, the call fails and the command
+		// stops like it does for other failures, saving its progress
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, fmt.Errorf("%w, run auth login %s where a browser can be used", errAuthorizationExpired, name)
+		}
+		fmt.Println(colorize(colorYellow, fmt.Sprintf("The %s account's authorization has expired or been revoked, authorize it again to continue", name)))
+		return authorize(), nil
+	})
 }
 
 type ChannelImportStatus struct {
-	Channel  *youtube.Subscription
-	Imported bool
+	Channel  *youtube.Subscription `json:"channel"`
+	Imported bool                  `json:"imported"`
+	// Unavailable is set when the target account can't see the channel,
+	// usually because it is blocked or hidden in the account's region
+	Unavailable bool `json:"unavailable,omitempty"`
+	// Attempts is how many times subscribing to the channel was tried
+	Attempts int `json:"attempts,omitempty"`
+	// Failure is why subscribing to the channel last failed, if it did
+	Failure *channelFailure `json:"failure,omitempty"`
+	// Tags are the groups the channel was in where it was imported from,
+	// such as PocketTube groups
+	Tags []string `json:"tags,omitempty"`
+}
+
+// channelFailure describes why subscribing to a channel failed.
+type channelFailure struct {
+	Time time.Time `json:"time"`
+	// Reason is the reason the API gave, such as subscriptionForbidden
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error"`
+	// Permanent is set for failures retrying won't fix, such as the
+	// channel being deleted, so later runs skip the channel
+	Permanent bool `json:"permanent,omitempty"`
+}
+
+// skipped reports whether transfers leave the channel alone, because the
+// target account can't see it or subscribing to it failed permanently.
+func (channelStatus ChannelImportStatus) skipped() bool {
+	return channelStatus.Unavailable || (channelStatus.Failure != nil && channelStatus.Failure.Permanent)
+}
+
+// clientSecretFile is the API project's OAuth client secret, downloaded from
+// the Google Cloud console.
+var clientSecretFile = "client_secret.json"
+
+// sourceClientSecretFile and targetClientSecretFile, if set, are the client
+// secrets of each account, so each can authorize against its own project.
+var sourceClientSecretFile, targetClientSecretFile string
+
+// clientSecretEnv holds the contents of the client secret, in place of the
+// -client-secret file, so it doesn't have to be on disk. A -client-secret
+// passed on the command line is used instead.
+const clientSecretEnv = "CLIENT_SECRET_JSON"
+
+// stdinClientSecret is the client secret read from stdin, which can only be
+// read once.
+var stdinClientSecret []byte
+
+// readClientSecret reads the client secret of the source or target account.
+// A file named - is read from stdin.
+func readClientSecret(account string) []byte {
+	file := clientSecretFile
+	if account == "source" && sourceClientSecretFile != "" {
+		file = sourceClientSecretFile
+	} else if account == "target" && targetClientSecretFile != "" {
+		file = targetClientSecretFile
+	} else if secret := os.Getenv(clientSecretEnv); secret != "" && !passedFlags["client-secret"] {
+		return []byte(secret)
+	}
+
+	if file == "-" {
+		if stdinClientSecret == nil {
+			secret, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatalf("Unable to read client secret from stdin: %v", err)
+			}
+			stdinClientSecret = secret
+		}
+		return stdinClientSecret
+	}
+
+	clientSecret, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("Unable to read client secret file: %v", err)
+	}
+	return clientSecret
+}
+
+// getService authorizes a YouTube client for kind, the source or target
+// account or a variant of them with other scopes such as source-manage.
+func getService(ctx context.Context, kind string, scope ...string) *youtube.Service {
+	if kind == "source" && sourceChannelID != "" {
+		// Public subscriptions can be listed by any account, so the
+		// target's credentials, with the scope it is authorized for, do
+		return newService(ctx, "target", readClientSecret("target"), youtube.YoutubeForceSslScope)
+	}
+
+	account := "target"
+	if strings.HasPrefix(kind, "source") {
+		account = "source"
+	}
+
+	return newService(ctx, kind, readClientSecret(account), scope...)
 }
 
-func getService(ctx context.Context, kind string, clientSecret []byte, scope ...string) *youtube.Service {
+// newService returns a service authorized against the API project of the
+// client secret, with the credentials cached as kind.
+func newService(ctx context.Context, kind string, clientSecret []byte, scope ...string) *youtube.Service {
 	// If modifying these scopes, delete your previously saved credentials
-	// at ~/.credentials/kind.json
+	// with auth revoke kind
 	config, err := google.ConfigFromJSON(clientSecret, scope...)
 	if err != nil {
 		log.Fatalf("Unable to parse client secret file to config: %v", err)
 	}
 	client := getClient(ctx, config, kind)
+	if quotaUser != "" {
+		client.Transport = &quotaUserTransport{quotaUser: quotaUser, base: client.Transport}
+	}
 
 	service, err := youtube.NewService(ctx, option.WithHTTPClient(client))
 
 	if err != nil {
 		log.Fatalf("Unable to get source youtube account: %v", err)
 	}
+	service.UserAgent = userAgent
 
 	return service
 }
 
-func writeStatusesToFile(channelStatuses []ChannelImportStatus) error {
-	// Write status to file
-	encodeFile, err := os.Create("importStatus.gob")
+// repeatedFlag collects the values of a flag that can be given repeatedly.
+type repeatedFlag []string
 
-	if err != nil {
-		return err
+func (flag *repeatedFlag) String() string {
+	return strings.Join(*flag, ", ")
+}
+
+func (flag *repeatedFlag) Set(value string) error {
+	*flag = append(*flag, value)
+	return nil
+}
+
+// command is a subcommand, named as the first argument.
+type command struct {
+	// flags creates the command's flags, returning them along with the
+	// function running the command once they are parsed from args
+	flags       func() (*flag.FlagSet, func(args []string))
+	description string
+}
+
+// commands are the subcommands. Without one the transfer is run, so flags
+// alone keep working as they did before there were subcommands.
+var commands = map[string]command{
+	"auth":           {authLoginCommand, "authorize the source and target accounts ahead of time"},
+	"dashboard":      {dashboardCommand, "serve a page charting the recorded runs"},
+	"diff":           {diffCommand, "list the channels added or changed in a SQLite state file since a run"},
+	"export":         {exportCommand, "export the source account's subscriptions to another service or file"},
+	"fsck":           {fsckCommand, "fix the state file where it disagrees with the target account"},
+	"history":        {historyCommand, "list the recorded runs"},
+	"import":         {importCommand, "add the channels in a file to the state file"},
+	"mark-imported":  {markImportedCommand, "mark channels in the state file as imported"},
+	"mark-pending":   {markPendingCommand, "mark channels in the state file as pending to try them again"},
+	"pipeline":       {pipelineCommand, "run a pipeline file"},
+	"plan":           {planCommand, "show what the next transfer would do and its quota cost"},
+	"preview-target": {previewTargetCommand, "show what the target account will look like after the transfer"},
+	"prune":          {pruneCommand, "unsubscribe from deleted and inactive channels"},
+	"query":          {queryCommand, "run SQL against the channels and runs"},
+	"refresh":        {refreshCommand, "add channels newly subscribed to on the source account to the state file"},
+	"reset":          {resetCommand, "delete the state file to start over"},
+	"rules":          {rulesCommand, "explain what a rules file decides for each channel"},
+	"serve":          {serveCommand, "serve several users, each transferring between their own accounts"},
+	"status":         {statusCommand, "summarize the progress of the transfer"},
+	"transfer":       {transferCommand, "subscribe the target account to the source account's channels (the default)"},
+	"undo":           {undoCommand, "unsubscribe the target account from the channels the tool subscribed it to"},
+	"watch":          {watchCommand, "notify about changes to the source account's subscriptions"},
+}
+
+// subcommands are the commands' own subcommands, named as the argument after
+// the command. Without one the command itself is run.
+var subcommands = map[string]map[string]command{
+	"auth":  authCommands,
+	"serve": serveCommands,
+}
+
+// runCommand parses the flags of the named command, or of its subcommand
+// named by the first argument, from args and runs it.
+func runCommand(name string, args []string) {
+	command := commands[name]
+	if len(args) > 0 {
+		if subcommand, ok := subcommands[name][args[0]]; ok {
+			command, args = subcommand, args[1:]
+		}
 	}
 
-	encoder := gob.NewEncoder(encodeFile)
+	flags, run := command.flags()
+	parseFlags(flags, args)
+	checkPermissions()
+	run(args)
+}
 
-	fmt.Println("Encoding channelStatuses to file")
-	if err := encoder.Encode(channelStatuses); err != nil {
-		return err
+// commandNames returns the names of the commands, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-	encodeFile.Close()
+	sort.Strings(names)
+	return names
+}
 
-	return nil
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [COMMAND] [FLAGS]\n\nCommands:\n", os.Args[0])
+	for _, name := range commandNames() {
+		fmt.Fprintf(os.Stderr, "  %-16s%s\n", name, commands[name].description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s COMMAND -h for the flags of a command.\n", os.Args[0])
 }
 
 func main() {
-	ctx := context.Background()
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Unable to read config file: %v", err)
+	}
 
-	clientSecret, err := ioutil.ReadFile("client_secret.json")
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if args[0] == "help" {
+			printUsage()
+			return
+		}
+		if _, ok := commands[args[0]]; ok {
+			runCommand(args[0], args[1:])
+			return
+		}
 	}
 
-	sourceService := getService(ctx, "source", clientSecret, youtube.YoutubeReadonlyScope)
-	targetService := getService(ctx, "target", clientSecret, youtube.YoutubeForceSslScope)
+	runCommand("transfer", args)
+}
 
-	handleError(err, "Error creating YouTube client")
+// transferCommand subscribes the target account to the channels in the
+// state file, first listing the source account's subscriptions into it if
+// there is none.
+func transferCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("transfer", flag.ExitOnError)
+	channelMapFile := flags.String("channel-map", "", "file mapping source channel IDs to the channel IDs to subscribe to instead")
+	refresh := flags.Bool("refresh", false, "list the source subscriptions again instead of using the ones listed by an earlier command")
+	rulesFile := flags.String("rules", "", "rules file deciding which of the source subscriptions to transfer, applied when they are first listed")
+	sourceValues := addSourceFlag(flags)
+	subscribedAfter := flags.String("subscribed-after", "", "only transfer channels the source account subscribed to after this date, e.g. 2022-01-01")
+	subscribedBefore := flags.String("subscribed-before", "", "only transfer channels the source account subscribed to before this date, e.g. 2024-06-30")
+	limit := flags.Int("limit", 0, "stop after subscribing to this many channels, to spread the transfer across days within the quota (0 for no limit)")
+	maxQuotaUnits := flags.Int("max-quota-units", 0, "stop cleanly before the run's calls would use more than this many quota units, at 50 units a subscription and 1 a lookup (0 for no budget)")
+	delay := flags.Duration("delay", 0, "wait at least this long between subscribing to channels, e.g. 2s, to stay clear of YouTube's rate limits on subscribing")
+	waitForQuota := flags.Bool("wait-for-quota", false, "when the quota is exceeded, wait for it to reset at midnight Pacific time and carry on instead of stopping, to finish a transfer of several days in one go")
+	var extraClientSecrets repeatedFlag
+	flags.Var(&extraClientSecrets, "extra-client-secret", "client secret file of another API project to carry on subscribing with once the quota is exceeded, can be repeated to rotate through several projects")
+	checkTarget := flags.Bool("check-target", true, "first list the target account's subscriptions and mark the channels it is already subscribed to imported, at 1 quota unit per 50 subscriptions instead of 50 per channel found to be a duplicate")
+	dryRun := flags.Bool("dry-run", false, "only list the channels that would be subscribed to and the quota that would cost, without changing the target account")
+	label := flags.String("label", "", "note stored with this run in the state file")
+	saveEvery := flags.Int("save-every", 1, "save the state file after this many processed channels (0 saves only at the end)")
+	saveInterval := flags.Duration("save-interval", 0, "save the state file when this much time has passed since the last save, e.g. 30s (0 disables)")
+	maxIdenticalFailures := flags.Int("max-identical-failures", defaultMaxIdenticalFailures, "stop after this many channels in a row fail with the same error, which points to a problem with the target account (0 never stops)")
+	quotaResetTimeZone := flags.String("quota-reset-tz", defaultQuotaResetTimeZone, "time zone the API project's daily quota resets at midnight in")
+	quotaLedgerFile := flags.String("quota-ledger", "", "file shared with other instances using the same API project to keep their combined quota use within -daily-quota")
+	dailyQuota := flags.Int("daily-quota", defaultDailyQuota, "daily quota units of the API project, for -quota-ledger, -dry-run and forecasting the days the transfer takes")
+	var notify repeatedFlag
+	flags.Var(&notify, "notify", "send progress to NAME=TARGET, e.g. webhook=https://example.com/hook, can be repeated: "+strings.Join(notifierNames(), ", "))
+	mqttBroker := flags.String("mqtt-broker", "", "MQTT broker to publish progress to, e.g. tcp://localhost:1883, same as -notify mqtt=BROKER")
+	notifyOptions := addNotifierFlags(flags, "progress")
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	addAuditLogFlag(flags)
+	schedule := addScheduleFlag(flags)
+	plain := flags.Bool("plain", false, "plain line by line output without colors or alignment, for screen readers and dumb terminals")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [transfer] [FLAGS]\n\nRun %s help for the other commands.\n\n", os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		if *plain {
+			setPlainOutput()
+		}
 
-	channelStatuses := make([]ChannelImportStatus, 0)
+		if *schedule != "" {
+			if err := runOnSchedule("transfer", *schedule, args); err != nil {
+				log.Fatalf("Unable to run on the schedule: %v", err)
+			}
+			return
+		}
 
-	// Find existing or create new channelStatuses
-	if file, err := os.Open("importStatus.gob"); err == nil {
-		fmt.Println("Encoded file exists, decoding into channelStatuses")
-		decoder := gob.NewDecoder(file)
+		ctx := context.Background()
+		defer setUpTelemetry(ctx)()
 
-		decoder.Decode(&channelStatuses)
+		quotaResetLocation, err := time.LoadLocation(*quotaResetTimeZone)
+		if err != nil {
+			log.Fatalf("Unable to load quota reset time zone: %v", err)
+		}
 
-		defer file.Close()
-	} else {
-		fmt.Println("Encoded file doesnt exist, fetching subscriptions")
-		sourceChannels, err := mySubscriptions(ctx, sourceService, []string{"snippet", "contentDetails"})
+		sources, err := parseTransferSources(*sourceValues)
+		if err != nil {
+			log.Fatalf("Invalid -source: %v", err)
+		}
 
+		after, err := parseDate(*subscribedAfter)
+		if err != nil {
+			log.Fatalf("Unable to parse -subscribed-after: %v", err)
+		}
+		before, err := parseDate(*subscribedBefore)
 		if err != nil {
-			log.Fatalf("Unable to list source channels: %v", err)
+			log.Fatalf("Unable to parse -subscribed-before: %v", err)
+		}
+
+		channelMap := make(map[string]string)
+		if *channelMapFile != "" {
+			channelMap, err = readChannelMap(*channelMapFile)
+			if err != nil {
+				log.Fatalf("Unable to read channel map file: %v", err)
+			}
 		}
 
-		fmt.Println("Importing into array")
+		targetService := getService(ctx, "target", youtube.YoutubeForceSslScope)
+
+		handleError(err, "Error creating YouTube client")
 
-		for _, channel := range sourceChannels {
-			channelStatuses = append(channelStatuses, ChannelImportStatus{channel, false})
+		if len(extraClientSecrets) > 0 && *quotaLedgerFile != "" {
+			log.Fatalf("-quota-ledger keeps track of a single API project's quota, so it can't be used with -extra-client-secret")
+		}
+		// The target account authorized against each API project in turn,
+		// each with its own cached credentials
+		targetServices := []*youtube.Service{targetService}
+		for index, file := range extraClientSecrets {
+			secret, err := ioutil.ReadFile(expandHome(file))
+			if err != nil {
+				log.Fatalf("Unable to read client secret file: %v", err)
+			}
+			kind := fmt.Sprintf("target-project-%d", index+2)
+			fmt.Printf("Authorizing the target account with the API project of %s\n", file)
+			targetServices = append(targetServices, newService(ctx, kind, secret, youtube.YoutubeForceSslScope))
 		}
 
-		if err := writeStatusesToFile(channelStatuses); err != nil {
-			panic(err)
+		var service *youtube.Service
+		sourceService := func() *youtube.Service {
+			if service == nil {
+				service = getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}
+			return service
+		}
+		if err := lookUpStateFileAccounts(ctx, func(account string) *youtube.Service {
+			if account == "source" {
+				return sourceService()
+			}
+			return targetService
+		}); err != nil {
+			log.Fatalf("Unable to look up the accounts to name the state file after: %v", err)
 		}
-	}
 
-	fmt.Printf("Importing up to %v unimported channels 1 by 1\n", len(channelStatuses))
-	for index, channelStatus := range channelStatuses {
-		channel := channelStatus.Channel
+		// addSourceChannels lists the sources into the state. A listing that
+		// stopped partway is recorded in the state, so the next transfer lists
+		// the sources again instead of taking the channels missed as gone.
+		addSourceChannels := func(state *importState, refresh bool) {
+			sourceChannels, sourceTags, err := readTransferSources(ctx, sources, sourceService, func() *youtube.Service { return targetService }, refresh)
+			if errors.Is(err, errIncompleteSources) {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: %v. Transferring the %s channels listed, the next transfer lists the sources again to add the rest",
+					err, formatCount(len(sourceChannels)))))
+			} else if err != nil {
+				log.Fatalf("Unable to list source channels: %v", err)
+			}
+			state.SourcesIncomplete = err != nil
 
-		channelToSubscribeTo := &youtube.Subscription{
-			Snippet: &youtube.SubscriptionSnippet{
-				ResourceId: &youtube.ResourceId{
-					ChannelId: channel.Snippet.ResourceId.ChannelId,
-					Kind:      "youtube#channel",
-				},
-			},
-		}
+			// Only the channels not in the state yet are looked at, the rest
+			// are already decided
+			known := make(map[string]bool)
+			for _, channelStatus := range state.Channels {
+				known[channelStatus.Channel.Snippet.ResourceId.ChannelId] = true
+			}
+			var newChannels []*youtube.Subscription
+			for _, channel := range sourceChannels {
+				if !known[channel.Snippet.ResourceId.ChannelId] {
+					newChannels = append(newChannels, channel)
+				}
+			}
+			sourceChannels = newChannels
+
+			if *rulesFile != "" {
+				rules, err := readRules(*rulesFile)
+				if err != nil {
+					log.Fatalf("Unable to read rules %s: %v", *rulesFile, err)
+				}
+				// Looking the channels up reads, so it uses the source account's
+				// quota and leaves the target's for subscribing, unless only
+				// files and other accounts are read
+				lookup := targetService
+				if usesSourceAccount(sources) {
+					lookup = sourceService()
+				}
+				decisions, err := applyRules(ctx, lookup, rules, sourceChannels)
+				if err != nil {
+					log.Fatalf("Unable to look up channel details: %v", err)
+				}
+				sourceChannels = keptChannels(decisions)
+			}
 
-		fmt.Printf("Attempting to add channel #%v/%v: %s: ", index, len(channelStatuses)-1, channel.Snippet.Title)
+			fmt.Println("Importing into array")
 
-		if channelStatus.Imported {
-			fmt.Printf("already imported, skipping\n")
-			continue
+			if added := state.addChannels(sourceChannels); len(state.Channels) > added {
+				fmt.Printf("Added %s channels missed by the last listing\n", formatCount(added))
+			}
+			state.tagChannels(sourceTags)
 		}
 
-		call := targetService.Subscriptions.Insert([]string{"snippet"}, channelToSubscribeTo)
-		_, err := call.Do()
-
+		// Find existing or create new state
+		state, err := readStateFromFile(stateFile)
 		if err == nil {
-			fmt.Printf("successfully subscribed to channel\n")
-			channelStatuses[index].Imported = true
+			fmt.Println("Encoded file exists, decoding into state")
+
+			if len(state.Runs) > 0 {
+				fmt.Println("Previous runs:")
+				for _, run := range state.Runs {
+					fmt.Printf("  %v\n", run)
+				}
+			}
+
+			if state.SourcesIncomplete {
+				fmt.Println("Listing the sources stopped partway last time, listing them again")
+				addSourceChannels(state, true)
+				if err := writeStateToFile(stateFile, state); err != nil {
+					log.Fatalf("Unable to save state: %v", err)
+				}
+			}
+		} else if os.IsNotExist(err) {
+			fmt.Println("Encoded file doesnt exist, fetching subscriptions")
+			state = &importState{}
+			addSourceChannels(state, *refresh)
+
+			if err := writeStateToFile(stateFile, state); err != nil {
+				panic(err)
+			}
 		} else {
-			if strings.HasSuffix(err.Error(), "subscriptionDuplicate") {
-				fmt.Printf("previously subscribed, marking as imported (%v)\n", err)
+			log.Fatalf("Unable to read state file: %v", err)
+		}
 
-				channelStatuses[index].Imported = true
-			} else if strings.HasSuffix(err.Error(), "quotaExceeded") {
-				fmt.Printf(" quota exceeded, can't import any more today. Stopping\n")
+		var ledger *quotaLedger
+		if *quotaLedgerFile != "" {
+			ledger = newQuotaLedger(*quotaLedgerFile, *dailyQuota, quotaResetLocation)
+		}
+
+		// quotaUsedBefore is the quota listing the target account's
+		// subscriptions used, counted in the run like its other calls
+		quotaUsedBefore := 0
+		// markTargetSubscribed lists the target account's subscriptions and
+		// marks the pending channels it is already subscribed to imported,
+		// returning how many it marked
+		markTargetSubscribed := func() (int, error) {
+			fmt.Println("Fetching target account subscriptions to skip the channels already subscribed to")
+			subscribed, err := subscribedChannels(ctx, targetService, func(cost int) error {
+				if *maxQuotaUnits > 0 && quotaUsedBefore+cost > *maxQuotaUnits {
+					return &quotaSpendError{errRunBudgetSpent}
+				}
+				if ledger != nil {
+					if err := ledger.reserve(cost); err != nil {
+						return &quotaSpendError{err}
+					}
+				}
+				quotaUsedBefore += cost
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+			return markSubscribedImported(state, subscribed, channelMap), nil
+		}
+
+		if *dryRun {
+			fmt.Println("Dry run, the target account won't be changed")
+			var actions []plannedAction
+			if *checkTarget {
+				// The channels are only marked for the plan, the state file
+				// is left as it is
+				if _, err := markTargetSubscribed(); err != nil {
+					fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to list the target account's subscriptions, the plan includes channels already subscribed to: %v", err)))
+				}
+				actions = append(actions, plannedAction{action: "list the target account's subscriptions", cost: quotaUsedBefore})
+			}
+			options := transferOptions{channelMap: channelMap, subscribedAfter: after, subscribedBefore: before, limit: *limit, maxQuotaUnits: *maxQuotaUnits, quotaUsedBefore: quotaUsedBefore}
+			printPlan(append(actions, planTransfer(state, options)...), len(state.Channels), *dailyQuota)
+			return
+		}
+
+		for index, service := range targetServices {
+			if err := checkAccountChannel(ctx, service, state, "target"); err != nil && index == 0 {
+				log.Fatalf("Unable to use the target account: %v", err)
+			} else if err != nil {
+				log.Fatalf("Unable to use the target account with the API project of %s: %v", extraClientSecrets[index-1], err)
+			}
+		}
+
+		if *checkTarget {
+			if marked, err := markTargetSubscribed(); err != nil {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to list the target account's subscriptions, channels already subscribed to will be found when subscribing: %v", err)))
+			} else if marked > 0 {
+				fmt.Printf("The target account is already subscribed to %s pending channels, marked them imported\n", formatCount(marked))
+				if err := writeStateToFile(stateFile, state); err != nil {
+					log.Fatalf("Unable to save state: %v", err)
+				}
+			}
+		}
+
+		printForecast(state, transferOptions{channelMap: channelMap, quotaResetLocation: quotaResetLocation, subscribedAfter: after, subscribedBefore: before}, *dailyQuota*len(targetServices))
+
+		var transferer *Transferer
+		saver := newAutosaver(func() error { return transferer.Checkpoint() }, *saveEvery, *saveInterval)
+
+		if *mqttBroker != "" {
+			notify = append(notify, "mqtt="+*mqttBroker)
+		}
+		progress, err := newNotifiers(notify, *notifyOptions)
+		if err != nil {
+			log.Fatalf("Unable to set up notifications: %v", err)
+		}
+		defer progress.close()
+
+		publishProgress := func(state, channel string) {
+			imported, total := transferer.Progress()
+			if err := progress.notify(newProgressUpdate(state, imported, total, channel)); err != nil {
+				log.Printf("Unable to publish progress: %v", err)
+			}
+		}
+
+		controls := startKeyboardControls()
+		defer controls.stop()
+		stopping := handleShutdown(controls.stop)
+		saveOnPause := func() {
+			if err := saver.save(); err != nil {
+				log.Printf("Unable to save state: %v", err)
+			}
+		}
+
+		transferer = newTransferer(targetService, stateFile, state, transferOptions{
+			channelMap:           channelMap,
+			quotaResetLocation:   quotaResetLocation,
+			maxIdenticalFailures: *maxIdenticalFailures,
+			ledger:               ledger,
+			auditLog:             newAuditLog(auditLogFile),
+			retries:              retries,
+			subscribedAfter:      after,
+			subscribedBefore:     before,
+			limit:                *limit,
+			maxQuotaUnits:        *maxQuotaUnits,
+			quotaUsedBefore:      quotaUsedBefore,
+			limiter:              newFixedDelay(*delay),
+			skip: func() bool {
+				return controls.shouldSkip(stopping, saveOnPause)
+			},
+			processed: func(channel *youtube.Subscription) {
+				if err := saver.channelProcessed(); err != nil {
+					log.Printf("Unable to save state: %v", err)
+				}
+				publishProgress("running", channel.Snippet.Title)
+			},
+		})
+
+		project := 0
+		for {
+			run, err := transferer.Run(stopping, *label)
+			if err != nil {
+				log.Printf("Unable to save state: %v", err)
+			}
+			if !run.QuotaExceeded || stopping.Err() != nil {
 				break
-			} else {
-				fmt.Printf("stopping with error: %v\n", err)
-				//panic(err)
 			}
+			if project+1 < len(targetServices) {
+				project++
+				fmt.Printf("Carrying on with the API project of %s\n", extraClientSecrets[project-1])
+				transferer.SetTarget(targetServices[project])
+				continue
+			}
+			if !*waitForQuota {
+				break
+			}
+			publishProgress("waiting", "")
+			if !waitForQuotaReset(stopping, quotaResetLocation) {
+				break
+			}
+			project = 0
+			transferer.SetTarget(targetServices[project])
 		}
-	}
 
-	writeStatusesToFile(channelStatuses)
+		if imported, total := transferer.Progress(); imported == total {
+			publishProgress("completed", "")
+		} else {
+			publishProgress("stopped", "")
+		}
+	}
 }
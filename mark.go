@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// resetCommand deletes the state file once confirmed, so the next transfer
+// starts over by listing the source account's subscriptions again.
+func resetCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("reset", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "delete the state file without asking for confirmation")
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		file, err := resolveStateFile(stateFile)
+		if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+		state, err := readStateFromFile(file)
+		if os.IsNotExist(err) {
+			fmt.Println("There is no state file to reset")
+			return
+		} else if err != nil {
+			fmt.Printf("Unable to read state file: %v\n", err)
+		} else {
+			imported := 0
+			for _, channelStatus := range state.Channels {
+				if channelStatus.Imported {
+					imported++
+				}
+			}
+			fmt.Printf("%s holds %s channels, %s of them imported, and %s recorded runs\n",
+				file, formatCount(len(state.Channels)), formatCount(imported), formatCount(len(state.Runs)))
+		}
+
+		if !*yes && !confirm(fmt.Sprintf("Delete %s?", file)) {
+			return
+		}
+		if err := os.Remove(file); err != nil {
+			log.Fatalf("Unable to delete state file: %v", err)
+		}
+		fmt.Println("Deleted the state file, the next transfer starts over")
+	}
+}
+
+// markImportedCommand marks channels in the state file as imported, so the
+// transfer leaves them alone.
+func markImportedCommand() (*flag.FlagSet, func(args []string)) {
+	return markCommand("mark-imported", func(channelStatus *ChannelImportStatus) {
+		channelStatus.Imported = true
+		channelStatus.Failure = nil
+	})
+}
+
+// markPendingCommand marks channels in the state file as pending, so the
+// next transfer tries them again.
+func markPendingCommand() (*flag.FlagSet, func(args []string)) {
+	return markCommand("mark-pending", func(channelStatus *ChannelImportStatus) {
+		channelStatus.Imported = false
+		channelStatus.Unavailable = false
+		channelStatus.Failure = nil
+	})
+}
+
+// markCommand applies mark to the channels with the IDs given as arguments
+// and saves the state file.
+func markCommand(name string, mark func(channelStatus *ChannelImportStatus)) (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	addStateFileFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [FLAGS] CHANNEL_ID...\n", os.Args[0], name)
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		if flags.NArg() == 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		marked := 0
+		for _, channelID := range flags.Args() {
+			found := false
+			for index := range state.Channels {
+				channelStatus := &state.Channels[index]
+				if channelStatus.Channel.Snippet.ResourceId.ChannelId != channelID {
+					continue
+				}
+				found = true
+				mark(channelStatus)
+				fmt.Printf("Marked %s %s\n", channelID, channelStatus.Channel.Snippet.Title)
+			}
+			if found {
+				marked++
+			} else {
+				fmt.Printf("Channel %s isn't in the state file\n", channelID)
+			}
+		}
+
+		if marked == 0 {
+			os.Exit(1)
+		}
+		if err := writeStateToFile(stateFile, state); err != nil {
+			log.Fatalf("Unable to save state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+type minifluxCategory struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// exportToMiniflux adds each channel's RSS feed to a Miniflux instance,
+// creating categories as needed.
+func exportToMiniflux(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	if options.url == "" || options.token == "" {
+		return errors.New("the Miniflux instance URL and an API key are required, pass them with -url and -token")
+	}
+
+	api := strings.TrimSuffix(options.url, "/") + "/v1"
+	header := http.Header{"X-Auth-Token": {options.token}}
+
+	var categories []minifluxCategory
+	if err := jsonRequest(ctx, http.MethodGet, api+"/categories", header, nil, &categories); err != nil {
+		return err
+	}
+	categoryIDs := make(map[string]int64)
+	for _, category := range categories {
+		categoryIDs[category.Title] = category.ID
+	}
+
+	for index, subscription := range subscriptions {
+		channelID := subscription.Snippet.ResourceId.ChannelId
+		categoryTitle := options.category(channelID)
+
+		fmt.Printf("Adding feed %s: %s: ", channelNumber(index, len(subscriptions)), displayTitle(subscription.Snippet.Title, titleWidth))
+
+		categoryID, ok := categoryIDs[categoryTitle]
+		if !ok {
+			var category minifluxCategory
+			if err := jsonRequest(ctx, http.MethodPost, api+"/categories", header, minifluxCategory{Title: categoryTitle}, &category); err != nil {
+				fmt.Printf("unable to create category %s: %v\n", categoryTitle, err)
+				continue
+			}
+			categoryID = category.ID
+			categoryIDs[categoryTitle] = categoryID
+		}
+
+		feed := struct {
+			FeedURL    string `json:"feed_url"`
+			CategoryID int64  `json:"category_id"`
+		}{channelFeedURL(channelID), categoryID}
+		if err := jsonRequest(ctx, http.MethodPost, api+"/feeds", header, feed, nil); err != nil {
+			fmt.Printf("failed: %v\n", err)
+			continue
+		}
+
+		fmt.Printf("added to %s\n", categoryTitle)
+	}
+
+	return nil
+}
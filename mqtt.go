@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttProgress publishes progress updates to an MQTT topic. Updates are
+// retained so dashboards show the latest progress as soon as they connect.
+type mqttProgress struct {
+	client mqtt.Client
+	topic  string
+}
+
+func newMQTTProgress(broker string, options notifierOptions) (notifier, error) {
+	if broker == "" {
+		return nil, errors.New("expected a broker, e.g. mqtt=tcp://localhost:1883")
+	}
+
+	client := mqtt.NewClient(mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(mqttClientID()).
+		SetUsername(options.username).
+		SetPassword(options.password))
+	token := client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		return nil, fmt.Errorf("timed out connecting to %s", broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+
+	return &mqttProgress{client: client, topic: options.topic}, nil
+}
+
+// mqttClientID returns a client ID of its own for each connection, as
+// brokers disconnect a client when another connects with the same ID, and
+// several transfers, or several notifiers in one, may publish at once.
+func mqttClientID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("youtube-subscriptions-transfer-%s-%d-%x", hostname, os.Getpid(), suffix)
+}
+
+func (progress *mqttProgress) notify(update progressUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	token := progress.client.Publish(progress.topic, 1, true, payload)
+	token.WaitTimeout(10 * time.Second)
+	return token.Error()
+}
+
+func (progress *mqttProgress) close() {
+	progress.client.Disconnect(250)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	newPipeDatabase = "newpipe.db"
+
+	// newPipeYouTubeService is NewPipe's service ID for YouTube
+	newPipeYouTubeService = 0
+)
+
+// exportToNewPipeBackup adds the channels to the subscriptions in the
+// database of an existing NewPipe backup zip, writing a copy of the backup
+// ready to be restored in NewPipe.
+func exportToNewPipeBackup(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	if options.backup == "" {
+		return errors.New("an existing NewPipe backup zip is required, export one in NewPipe's settings and pass it with -backup")
+	}
+
+	output := options.output
+	if output == "" {
+		output = strings.TrimSuffix(options.backup, ".zip") + "-with-subscriptions.zip"
+	}
+
+	backup, err := zip.OpenReader(options.backup)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	var databaseFile *zip.File
+	for _, file := range backup.File {
+		if file.Name == newPipeDatabase {
+			databaseFile = file
+		}
+	}
+	if databaseFile == nil {
+		return fmt.Errorf("%s does not contain %s, is it a NewPipe backup?", options.backup, newPipeDatabase)
+	}
+
+	// SQLite needs the database on disk
+	database, err := ioutil.TempFile("", "newpipe-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(database.Name())
+
+	if err := extractZipFile(databaseFile, database); err != nil {
+		return err
+	}
+
+	added, err := addNewPipeSubscriptions(ctx, database.Name(), subscriptions)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %v of %v channels to the NewPipe subscriptions, the rest were already subscribed\n", added, len(subscriptions))
+
+	if err := writeNewPipeBackup(output, backup, database.Name()); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote NewPipe backup to %s\n", output)
+
+	return nil
+}
+
+func extractZipFile(file *zip.File, destination *os.File) error {
+	defer destination.Close()
+
+	contents, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	_, err = io.Copy(destination, contents)
+	return err
+}
+
+// addNewPipeSubscriptions inserts the channels missing from the NewPipe
+// database's subscriptions and returns how many were added.
+func addNewPipeSubscriptions(ctx context.Context, file string, subscriptions []*youtube.Subscription) (int64, error) {
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// Older NewPipe versions don't have notification modes
+	hasNotificationMode := false
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info('subscriptions')")
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hasNotificationMode = hasNotificationMode || column == "notification_mode"
+	}
+	rows.Close()
+
+	insert := "INSERT OR IGNORE INTO subscriptions (service_id, url, name, avatar_url, description) VALUES (?, ?, ?, ?, ?)"
+	if hasNotificationMode {
+		insert = "INSERT OR IGNORE INTO subscriptions (service_id, url, name, avatar_url, description, notification_mode) VALUES (?, ?, ?, ?, ?, 0)"
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var added int64
+	for _, subscription := range subscriptions {
+		snippet := subscription.Snippet
+
+		avatarURL := ""
+		if snippet.Thumbnails != nil && snippet.Thumbnails.Default != nil {
+			avatarURL = snippet.Thumbnails.Default.Url
+		}
+
+		result, err := tx.ExecContext(ctx, insert, newPipeYouTubeService, channelURL(snippet.ResourceId.ChannelId), snippet.Title, avatarURL, snippet.Description)
+		if err != nil {
+			return 0, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += inserted
+	}
+
+	return added, tx.Commit()
+}
+
+// writeNewPipeBackup copies the backup to output, replacing its database.
+// The copy is written next to output and only replaces it once complete, so
+// output can be the backup itself, which is still being read from.
+func writeNewPipeBackup(output string, backup *zip.ReadCloser, database string) error {
+	return writeFileAtomically(output, 0600, func(w io.Writer) error {
+		archive := zip.NewWriter(w)
+		for _, file := range backup.File {
+			if file.Name == newPipeDatabase {
+				continue
+			}
+			if err := archive.Copy(file); err != nil {
+				return err
+			}
+		}
+
+		contents, err := os.Open(database)
+		if err != nil {
+			return err
+		}
+		defer contents.Close()
+
+		entry, err := archive.Create(newPipeDatabase)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(entry, contents); err != nil {
+			return err
+		}
+
+		return archive.Close()
+	})
+}
+
+// newPipeSubscriptions is the file NewPipe exports its subscriptions to and
+// imports them from, in the subscriptions settings.
+type newPipeSubscriptions struct {
+	Subscriptions []newPipeSubscription `json:"subscriptions"`
+}
+
+type newPipeSubscription struct {
+	ServiceID int    `json:"service_id"`
+	URL       string `json:"url"`
+	Name      string `json:"name"`
+}
+
+// readNewPipe finds the YouTube channels in a NewPipe subscriptions export,
+// leaving out the other services NewPipe supports.
+func readNewPipe(file string, options importOptions) ([]channelReference, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var export newPipeSubscriptions
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	var references []channelReference
+	for _, subscription := range export.Subscriptions {
+		if subscription.ServiceID != newPipeYouTubeService {
+			continue
+		}
+		if reference, ok := parseChannelURL(subscription.URL); ok {
+			reference.title = subscription.Name
+			references = append(references, reference)
+		}
+	}
+	return references, nil
+}
+
+// exportToNewPipe writes the channels as a NewPipe subscriptions export,
+// ready to be imported in NewPipe's subscriptions settings.
+func exportToNewPipe(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	export := newPipeSubscriptions{Subscriptions: make([]newPipeSubscription, 0, len(subscriptions))}
+	for _, subscription := range subscriptions {
+		export.Subscriptions = append(export.Subscriptions, newPipeSubscription{
+			ServiceID: newPipeYouTubeService,
+			URL:       channelURL(subscription.Snippet.ResourceId.ChannelId),
+			Name:      subscription.Snippet.Title,
+		})
+	}
+
+	return writeExport(options.outputOr("newpipe_subscriptions.json"), func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	})
+}
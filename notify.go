@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// progressUpdate is the progress of a run as sent to notifiers.
+type progressUpdate struct {
+	// State is running, waiting for the quota to reset, stopped or
+	// completed, or changed when watching
+	State    string  `json:"state"`
+	Imported int     `json:"imported"`
+	Total    int     `json:"total"`
+	Percent  float64 `json:"percent"`
+	Channel  string  `json:"channel,omitempty"`
+
+	// Message, Added and Removed describe the changes to the source
+	// account's subscriptions when watching
+	Message string   `json:"message,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+func newProgressUpdate(state string, imported, total int, channel string) progressUpdate {
+	update := progressUpdate{State: state, Imported: imported, Total: total, Channel: channel}
+	if total > 0 {
+		update.Percent = float64(imported) / float64(total) * 100
+	}
+	return update
+}
+
+// summary describes the update in a sentence, for notifiers sending it to
+// people rather than to other programs.
+func (update progressUpdate) summary() string {
+	progress := fmt.Sprintf("%s of %s channels imported (%.0f%%)", formatCount(update.Imported), formatCount(update.Total), update.Percent)
+	switch update.State {
+	case "changed":
+		return update.Message
+	case "completed":
+		return fmt.Sprintf("Transfer completed, all %s channels imported", formatCount(update.Total))
+	case "waiting":
+		return "Transfer waiting for the quota to reset, " + progress
+	case "stopped":
+		return "Transfer stopped, " + progress
+	}
+	return "Transfer running, " + progress
+}
+
+// notifier sends progress updates somewhere, e.g. an MQTT broker, webhook,
+// Slack, email or the desktop.
+type notifier interface {
+	notify(update progressUpdate) error
+	close()
+}
+
+// notifierOptions are settings shared by all notifiers, each notifier uses
+// the ones that apply to it.
+type notifierOptions struct {
+	topic    string
+	username string
+	password string
+
+	smtpServer   string
+	smtpUsername string
+	smtpPassword string
+	smtpFrom     string
+}
+
+// addNotifierFlags adds the flags for the notifiers' settings to the flags
+// of a command sending what to them, e.g. progress.
+func addNotifierFlags(flags *flag.FlagSet, what string) *notifierOptions {
+	options := &notifierOptions{}
+	flags.StringVar(&options.topic, "mqtt-topic", "youtube-subscriptions-transfer/progress", "MQTT topic to publish "+what+" to")
+	flags.StringVar(&options.username, "mqtt-username", "", "username for the MQTT broker")
+	flags.StringVar(&options.password, "mqtt-password", "", "password for the MQTT broker")
+	flags.StringVar(&options.smtpServer, "smtp-server", "", "SMTP server to send email notifications through, as HOST:PORT")
+	flags.StringVar(&options.smtpUsername, "smtp-username", "", "username for the SMTP server")
+	flags.StringVar(&options.smtpPassword, "smtp-password", "", "password for the SMTP server")
+	flags.StringVar(&options.smtpFrom, "smtp-from", "", "address email notifications are sent from, -smtp-username by default")
+	return options
+}
+
+// notifiers are the backends progress can be sent to with -notify
+// NAME=TARGET, where the target is a broker, URL or similar. A backend is
+// added by registering its constructor here.
+var notifiers = map[string]func(target string, options notifierOptions) (notifier, error){
+	"desktop": newDesktopNotifier,
+	"email":   newEmailNotifier,
+	"mqtt":    newMQTTProgress,
+	"slack":   newSlackNotifier,
+	"webhook": newWebhookNotifier,
+}
+
+func notifierNames() []string {
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stateChangeNotifier only passes on the updates changing the state, and
+// every change found when watching, for notifiers reaching people, who don't
+// want a message for every channel subscribed to.
+type stateChangeNotifier struct {
+	notifier
+	state string
+}
+
+func onStateChange(n notifier) notifier {
+	return &stateChangeNotifier{notifier: n}
+}
+
+func (n *stateChangeNotifier) notify(update progressUpdate) error {
+	if update.State == n.state && update.State != "changed" {
+		return nil
+	}
+	if err := n.notifier.notify(update); err != nil {
+		return err
+	}
+	n.state = update.State
+	return nil
+}
+
+// notifierList sends each update to all of its notifiers.
+type notifierList []notifier
+
+// newNotifiers creates a notifier for each NAME=TARGET spec.
+func newNotifiers(specs []string, options notifierOptions) (notifierList, error) {
+	var list notifierList
+	for _, spec := range specs {
+		name, target, _ := strings.Cut(spec, "=")
+		newNotifier, ok := notifiers[name]
+		if !ok {
+			list.close()
+			return nil, fmt.Errorf("unknown notifier %q, expected one of: %s", name, strings.Join(notifierNames(), ", "))
+		}
+
+		n, err := newNotifier(target, options)
+		if err != nil {
+			list.close()
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func (list notifierList) notify(update progressUpdate) error {
+	var errs []error
+	for _, n := range list {
+		if err := n.notify(update); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (list notifierList) close() {
+	for _, n := range list {
+		n.close()
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/term"
+	"google.golang.org/api/youtube/v3"
+)
+
+// deviceAuth selects the device flow for authorizing accounts, set with
+// -device-auth.
+var deviceAuth bool
+
+// getTokenFromWeb has the user authorize an account in their browser. It
+// listens on a loopback address Google redirects back to with the
+// authorization code, so there is nothing to copy and paste.
+// It returns the retrieved Token.
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config, name string) *oauth2.Token {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		log.Fatalf("No cached credentials for the %s account and no one to authorize it, run auth where a browser can be used first", name)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Unable to listen for the authorization redirect: %v", err)
+	}
+
+	redirectConfig := *config
+	redirectConfig.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+	state, err := randomState()
+	if err != nil {
+		log.Fatalf("Unable to start authorization: %v", err)
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "Unexpected authorization response", http.StatusBadRequest)
+			return
+		}
+
+		var received result
+		if reason := query.Get("error"); reason != "" {
+			received.err = errors.New(reason)
+			fmt.Fprintf(w, "Authorizing the %s account failed: %s. You can close this window.\n", name, reason)
+		} else {
+			received.code = query.Get("code")
+			fmt.Fprintf(w, "The %s account is authorized, you can close this window.\n", name)
+		}
+		select {
+		case results <- received:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// The code is only exchanged along with the PKCE verifier, so it is of
+	// no use to anyone intercepting the redirect
+	verifier := oauth2.GenerateVerifier()
+
+	// Asking which account to use lets a Google account with several
+	// channels pick one
+	authURL := redirectConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("prompt", "select_account consent"))
+	fmt.Printf(name+" account: Opening the following link in your browser to authorize the account, "+
+		"open it yourself if it doesn't open: \n%v\n", authURL)
+	openBrowser(authURL)
+
+	var received result
+	select {
+	case received = <-results:
+	case <-ctx.Done():
+		log.Fatalf("Unable to authorize the %s account: %v", name, ctx.Err())
+	}
+	if received.err != nil {
+		log.Fatalf("Unable to authorize the %s account: %v", name, received.err)
+	}
+
+	tok, err := redirectConfig.Exchange(ctx, received.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		log.Fatalf("Unable to retrieve token from web %v", err)
+	}
+	return tok
+}
+
+// getTokenFromDevice has the user authorize an account on another device,
+// by entering a code shown here at Google's verification page, and polls
+// for the token meanwhile. The client secret must be for a "TVs and Limited
+// Input devices" client. It returns the retrieved Token.
+func getTokenFromDevice(ctx context.Context, config *oauth2.Config, name string) *oauth2.Token {
+	deviceConfig := *config
+	deviceConfig.Endpoint.DeviceAuthURL = google.Endpoint.DeviceAuthURL
+
+	// The device flow doesn't allow the youtube.force-ssl scope, youtube
+	// allows the same calls
+	deviceConfig.Scopes = nil
+	for _, scope := range config.Scopes {
+		if scope == youtube.YoutubeForceSslScope {
+			scope = youtube.YoutubeScope
+		}
+		deviceConfig.Scopes = append(deviceConfig.Scopes, scope)
+	}
+
+	response, err := deviceConfig.DeviceAuth(ctx)
+	if err != nil {
+		log.Fatalf("Unable to start authorizing the %s account: %v", name, err)
+	}
+	fmt.Printf(name+" account: On a device with a browser, go to %s and enter the code %s\n", response.VerificationURI, response.UserCode)
+
+	tok, err := deviceConfig.DeviceAccessToken(ctx, response)
+	if err != nil {
+		log.Fatalf("Unable to authorize the %s account: %v", name, err)
+	}
+	return tok
+}
+
+// randomState returns an unguessable state parameter, tying the redirect to
+// the authorization this process started.
+func randomState() (string, error) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(state), nil
+}
+
+// openBrowser tries to open a URL in the default browser, failing silently
+// since the URL is printed as well.
+func openBrowser(url string) {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		command = exec.Command("open", url)
+	default:
+		command = exec.Command("xdg-open", url)
+	}
+	if err := command.Start(); err == nil {
+		go command.Wait()
+	}
+}
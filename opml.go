@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// opml is an OPML 2.0 (http://opml.org/spec2.opml) outline of feeds, the
+// format RSS readers import and export subscriptions in.
+type opml struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Created string        `xml:"head>dateCreated,omitempty"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+// opmlOutline is a category holding feeds, or a feed with its XMLURL.
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// readOPML finds the YouTube channels among the feeds in an OPML file, in
+// any category, leaving out the other feeds.
+func readOPML(file string, options importOptions) ([]channelReference, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var document opml
+	if err := xml.NewDecoder(f).Decode(&document); err != nil {
+		return nil, err
+	}
+	return opmlReferences(document.Body), nil
+}
+
+func opmlReferences(outlines []opmlOutline) []channelReference {
+	var references []channelReference
+	for _, outline := range outlines {
+		reference, ok := parseChannelURL(outline.XMLURL)
+		if !ok {
+			reference, ok = parseChannelURL(outline.HTMLURL)
+		}
+		if ok {
+			reference.title = outline.Title
+			if reference.title == "" {
+				reference.title = outline.Text
+			}
+			references = append(references, reference)
+		}
+		references = append(references, opmlReferences(outline.Outlines)...)
+	}
+	return references
+}
+
+// exportToOPML writes an OPML file with each channel's RSS feed, filed
+// under its category like the feed reader exporters do.
+func exportToOPML(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	document := opml{Version: "2.0", Title: "YouTube subscriptions", Created: time.Now().Format(time.RFC1123Z)}
+
+	categories := make(map[string]int)
+	for _, subscription := range subscriptions {
+		channelID := subscription.Snippet.ResourceId.ChannelId
+		category := options.category(channelID)
+		index, ok := categories[category]
+		if !ok {
+			index = len(document.Body)
+			categories[category] = index
+			document.Body = append(document.Body, opmlOutline{Text: category, Title: category})
+		}
+
+		document.Body[index].Outlines = append(document.Body[index].Outlines, opmlOutline{
+			Text:    subscription.Snippet.Title,
+			Title:   subscription.Snippet.Title,
+			Type:    "rss",
+			XMLURL:  channelFeedURL(channelID),
+			HTMLURL: channelURL(channelID),
+		})
+	}
+
+	return writeExport(options.outputOr("subscriptions.opml"), func(f *os.File) error {
+		if _, err := f.WriteString(xml.Header); err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(f)
+		encoder.Indent("", "  ")
+		return encoder.Encode(document)
+	})
+}
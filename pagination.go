@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// mySubscriptions lists all of the account's subscriptions page by page,
+// retrying pages that fail to be fetched with the retry policy. If a page
+// still can't be fetched, the subscriptions listed until then are returned
+// along with the error, so callers can decide whether a partial list will
+// do.
+func mySubscriptions(ctx context.Context, service *youtube.Service, parts []string) ([]*youtube.Subscription, error) {
+	return listSubscriptions(ctx, service, "", parts)
+}
+
+// channelSubscriptions lists the subscriptions of another channel like
+// mySubscriptions, which only works if the channel made them public.
+func channelSubscriptions(ctx context.Context, service *youtube.Service, channelID string, parts []string) ([]*youtube.Subscription, error) {
+	subscriptions, err := listSubscriptions(ctx, service, channelID, parts)
+	var apiError *googleapi.Error
+	if errors.As(err, &apiError) && len(subscriptions) == 0 {
+		for _, item := range apiError.Errors {
+			if item.Reason == "subscriptionForbidden" {
+				// Not wrapped, as listing fewer parts won't help
+				return nil, fmt.Errorf("the channel %s keeps its subscriptions private: %v", channelID, err)
+			}
+		}
+	}
+	return subscriptions, err
+}
+
+// listSubscriptions lists the subscriptions of the channel, or of the
+// authorized account if channelID is empty.
+func listSubscriptions(ctx context.Context, service *youtube.Service, channelID string, parts []string) ([]*youtube.Subscription, error) {
+	return listSubscriptionsSpending(ctx, service, channelID, parts, nil)
+}
+
+// listSubscriptionsSpending lists the subscriptions like listSubscriptions,
+// calling spend, if not nil, with the quota of each request for a page,
+// retries included, before making it. The listing stops with the
+// subscriptions listed until then when spend returns an error.
+func listSubscriptionsSpending(ctx context.Context, service *youtube.Service, channelID string, parts []string, spend func(cost int) error) ([]*youtube.Subscription, error) {
+	ctx, span := startSpan(ctx, "list subscriptions")
+	defer span.End()
+
+	var channels = make([]*youtube.Subscription, 0)
+
+	pageToken := ""
+	for {
+		response, err := fetchSubscriptionsPage(ctx, service, channelID, parts, pageToken, spend)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "listing stopped")
+			return channels, fmt.Errorf("listing stopped after %v subscriptions: %w", len(channels), err)
+		}
+
+		channels = append(channels, response.Items...)
+
+		if response.NextPageToken == "" {
+			return channels, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+func fetchSubscriptionsPage(ctx context.Context, service *youtube.Service, channelID string, parts []string, pageToken string, spend func(cost int) error) (*youtube.SubscriptionListResponse, error) {
+	ctx, span := startSpan(ctx, "fetch subscriptions page")
+	defer span.End()
+
+	var response *youtube.SubscriptionListResponse
+	attempts, err := retries.do(ctx, func() error {
+		if spend != nil {
+			if err := spend(listCost); err != nil {
+				return err
+			}
+		}
+		call := service.Subscriptions.List(parts)
+		if channelID != "" {
+			call = call.ChannelId(channelID)
+		} else {
+			call = call.Mine(true)
+		}
+		var err error
+		response, err = call.
+			MaxResults(50).
+			PageToken(pageToken).
+			Context(ctx).
+			Do()
+		return err
+	}, func(err error, delay time.Duration) {
+		fmt.Printf("Fetching a page of subscriptions failed, retrying in %v: %v\n", delay.Round(time.Millisecond), err)
+	})
+	span.SetAttributes(attribute.Int("attempts", attempts))
+	return response, err
+}
+
+// isRetryable reports whether an API call failed for a reason that may go
+// away when trying again: network errors, server errors and rate limiting.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var notSpent *quotaSpendError
+	if errors.As(err, &notSpent) || errors.Is(err, errAuthorizationExpired) {
+		return false
+	}
+
+	var apiError *googleapi.Error
+	if !errors.As(err, &apiError) {
+		return true
+	}
+	if apiError.Code >= http.StatusInternalServerError || apiError.Code == http.StatusTooManyRequests {
+		return true
+	}
+	for _, item := range apiError.Errors {
+		if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// fakeYouTube is a fake of the YouTube API's subscriptions listing, serving
+// total subscriptions 50 to a page. fail, if set, can fail a request for a
+// page, counting from 1, by writing an error response and returning true.
+type fakeYouTube struct {
+	total int
+	fail  func(w http.ResponseWriter, r *http.Request, page int) bool
+
+	mu       sync.Mutex
+	requests map[int]int
+}
+
+func (fake *fakeYouTube) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/youtube/v3/subscriptions" {
+		http.NotFound(w, r)
+		return
+	}
+	page := 1
+	if token := r.URL.Query().Get("pageToken"); token != "" {
+		page, _ = strconv.Atoi(token)
+	}
+
+	fake.mu.Lock()
+	if fake.requests == nil {
+		fake.requests = make(map[int]int)
+	}
+	fake.requests[page]++
+	fake.mu.Unlock()
+
+	if fake.fail != nil && fake.fail(w, r, page) {
+		return
+	}
+
+	response := youtube.SubscriptionListResponse{}
+	for index := (page - 1) * 50; index < page*50 && index < fake.total; index++ {
+		response.Items = append(response.Items, &youtube.Subscription{
+			Id: fmt.Sprintf("subscription%d", index),
+			Snippet: &youtube.SubscriptionSnippet{
+				Title:      fmt.Sprintf("Channel %d", index),
+				ResourceId: &youtube.ResourceId{Kind: "youtube#channel", ChannelId: fmt.Sprintf("UC%022d", index)},
+			},
+		})
+	}
+	if page*50 < fake.total {
+		response.NextPageToken = strconv.Itoa(page + 1)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// requestsFor returns how many times the page was requested.
+func (fake *fakeYouTube) requestsFor(page int) int {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.requests[page]
+}
+
+// writeAPIError writes an error response like the API's.
+func writeAPIError(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":"%s","errors":[{"reason":"%s","message":"%s"}]}}`, code, reason, reason, reason)
+}
+
+// newFakeService starts the handler as a fake API server and returns a
+// service calling it with client.
+func newFakeService(t *testing.T, handler http.Handler, client *http.Client) *youtube.Service {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	service, err := youtube.NewService(context.Background(), option.WithHTTPClient(client), option.WithEndpoint(server.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// withRetries sets the retry policy for the test, without waiting between
+// attempts.
+func withRetries(t *testing.T, attempts int) {
+	previous := retries
+	retries = retryPolicy{attempts: attempts}
+	t.Cleanup(func() { retries = previous })
+}
+
+func checkListed(t *testing.T, subscriptions []*youtube.Subscription, want int) {
+	t.Helper()
+	if len(subscriptions) != want {
+		t.Fatalf("listed %d subscriptions, want %d", len(subscriptions), want)
+	}
+	for index, subscription := range subscriptions {
+		if want := fmt.Sprintf("UC%022d", index); subscription.Snippet.ResourceId.ChannelId != want {
+			t.Fatalf("subscription %d is %s, want %s", index, subscription.Snippet.ResourceId.ChannelId, want)
+		}
+	}
+}
+
+func TestListSubscriptionsPages(t *testing.T) {
+	withRetries(t, 3)
+	fake := &fakeYouTube{total: 120}
+	service := newFakeService(t, fake, http.DefaultClient)
+
+	subscriptions, err := mySubscriptions(context.Background(), service, []string{"snippet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkListed(t, subscriptions, 120)
+	for page := 1; page <= 3; page++ {
+		if requests := fake.requestsFor(page); requests != 1 {
+			t.Errorf("page %d requested %d times, want once", page, requests)
+		}
+	}
+}
+
+func TestListSubscriptionsRetriesFailedPage(t *testing.T) {
+	withRetries(t, 5)
+	fake := &fakeYouTube{total: 120}
+	fake.fail = func(w http.ResponseWriter, r *http.Request, page int) bool {
+		switch {
+		case page == 2 && fake.requestsFor(page) == 1:
+			writeAPIError(w, http.StatusServiceUnavailable, "backendError")
+		case page == 2 && fake.requestsFor(page) == 2:
+			writeAPIError(w, http.StatusForbidden, "rateLimitExceeded")
+		default:
+			return false
+		}
+		return true
+	}
+	service := newFakeService(t, fake, http.DefaultClient)
+
+	subscriptions, err := mySubscriptions(context.Background(), service, []string{"snippet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkListed(t, subscriptions, 120)
+	if requests := fake.requestsFor(2); requests != 3 {
+		t.Errorf("page 2 requested %d times, want 3", requests)
+	}
+}
+
+func TestListSubscriptionsReturnsPartialList(t *testing.T) {
+	withRetries(t, 3)
+	fake := &fakeYouTube{total: 120}
+	fake.fail = func(w http.ResponseWriter, r *http.Request, page int) bool {
+		if page == 2 {
+			writeAPIError(w, http.StatusInternalServerError, "backendError")
+			return true
+		}
+		return false
+	}
+	service := newFakeService(t, fake, http.DefaultClient)
+
+	subscriptions, err := mySubscriptions(context.Background(), service, []string{"snippet"})
+	if err == nil {
+		t.Fatal("listing succeeded, want an error for page 2")
+	}
+	checkListed(t, subscriptions, 50)
+	if requests := fake.requestsFor(2); requests != 3 {
+		t.Errorf("page 2 requested %d times, want 3", requests)
+	}
+	if requests := fake.requestsFor(3); requests != 0 {
+		t.Errorf("page 3 requested %d times, want none", requests)
+	}
+}
+
+func TestListSubscriptionsDoesNotRetryClientErrors(t *testing.T) {
+	withRetries(t, 3)
+	fake := &fakeYouTube{total: 120}
+	fake.fail = func(w http.ResponseWriter, r *http.Request, page int) bool {
+		writeAPIError(w, http.StatusForbidden, "forbidden")
+		return true
+	}
+	service := newFakeService(t, fake, http.DefaultClient)
+
+	if _, err := mySubscriptions(context.Background(), service, []string{"snippet"}); err == nil {
+		t.Fatal("listing succeeded, want an error")
+	}
+	if requests := fake.requestsFor(1); requests != 1 {
+		t.Errorf("page 1 requested %d times, want once", requests)
+	}
+}
+
+func TestListSubscriptionsRefreshesRejectedToken(t *testing.T) {
+	withRetries(t, 3)
+
+	// The access token is revoked after the first page, before its expiry
+	var refreshes int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"new","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	fake := &fakeYouTube{total: 120}
+	fake.fail = func(w http.ResponseWriter, r *http.Request, page int) bool {
+		if page > 1 && r.Header.Get("Authorization") != "Bearer new" {
+			writeAPIError(w, http.StatusUnauthorized, "authError")
+			return true
+		}
+		return false
+	}
+
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}}
+	token := &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+	client := newAuthorizedClient(context.Background(), config, token, nil)
+	service := newFakeService(t, fake, client)
+
+	subscriptions, err := mySubscriptions(context.Background(), service, []string{"snippet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkListed(t, subscriptions, 120)
+	if refreshes != 1 {
+		t.Errorf("token refreshed %d times, want once", refreshes)
+	}
+}
+
+func TestReadTransferSourcesIncomplete(t *testing.T) {
+	withRetries(t, 2)
+	fake := &fakeYouTube{total: 120}
+	fake.fail = func(w http.ResponseWriter, r *http.Request, page int) bool {
+		if page == 3 {
+			writeAPIError(w, http.StatusInternalServerError, "backendError")
+			return true
+		}
+		return false
+	}
+	service := newFakeService(t, fake, http.DefaultClient)
+	services := func() *youtube.Service { return service }
+
+	sources := []transferSource{{kind: "channel", value: "UC0000000000000000000000"}}
+	channels, _, err := readTransferSources(context.Background(), sources, services, services, true)
+	if !errors.Is(err, errIncompleteSources) {
+		t.Fatalf("got error %v, want one for incomplete sources", err)
+	}
+	checkListed(t, channels, 100)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// checkPermissions makes sure the files holding secrets or the subscription
+// list, and the directory tokens are cached in, can't be read by other users,
+// restricting their permissions if they can. It checks the files as set by
+// the config file and the command's flags, so it runs once they are parsed.
+func checkPermissions() {
+	// Windows doesn't use permission bits, files are private to the user
+	// through their ACLs by default
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	restrictPermissions(clientSecretFile)
+	restrictPermissions(sourceClientSecretFile)
+	restrictPermissions(targetClientSecretFile)
+	if file, err := resolveStateFile(stateFile); err == nil {
+		restrictPermissions(file)
+		restrictPermissions(file + backupSuffix)
+	}
+	restrictPermissions(legacyStateFile)
+	restrictPermissions(auditLogFile)
+	if stateKeyFile != "" {
+		restrictPermissions(expandHome(stateKeyFile))
+	}
+	if sourceChannel := accountChannels()["source"]; sourceChannel != "" {
+		restrictPermissions(sourceSnapshotFile(sourceChannel))
+	}
+
+	tokenCacheDir, err := tokenCacheDirPath()
+	if err != nil {
+		return
+	}
+	restrictPermissions(tokenCacheDir)
+	tokenFiles, err := ioutil.ReadDir(tokenCacheDir)
+	if err != nil {
+		return
+	}
+	for _, tokenFile := range tokenFiles {
+		restrictPermissions(filepath.Join(tokenCacheDir, tokenFile.Name()))
+	}
+}
+
+// restrictPermissions makes a file readable and writable only by its owner,
+// 0600 for files and 0700 for directories. Missing files are ignored.
+func restrictPermissions(file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+
+	var perm os.FileMode = 0600
+	if info.IsDir() {
+		perm = 0700
+	}
+	if info.Mode().Perm()&0077 == 0 {
+		return
+	}
+
+	if err := os.Chmod(file, perm); err != nil {
+		fmt.Println(colorize(colorRed, fmt.Sprintf("Warning: %s can be accessed by other users (%v) and its permissions could not be restricted: %v. Run chmod %o %s to fix this", file, info.Mode().Perm(), err, perm, file)))
+		return
+	}
+	fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: %s could be accessed by other users (%v), restricted its permissions to %v", file, info.Mode().Perm(), perm)))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// exportToPiped imports the channels into a Piped account in one request.
+// The URL is the instance's API URL, authenticated with either an existing
+// token or the account's username and password.
+func exportToPiped(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	if options.url == "" || (options.token == "" && (options.username == "" || options.password == "")) {
+		return errors.New("the Piped API URL and either a token or username and password are required, pass them with -url and -token or -username and -password")
+	}
+
+	api := strings.TrimSuffix(options.url, "/")
+
+	token := options.token
+	if token == "" {
+		credentials := map[string]string{"username": options.username, "password": options.password}
+		var login struct {
+			Token string `json:"token"`
+			Error string `json:"error"`
+		}
+		if err := jsonRequest(ctx, http.MethodPost, api+"/login", nil, credentials, &login); err != nil {
+			return err
+		}
+		if login.Token == "" {
+			return fmt.Errorf("unable to log in to Piped: %s", login.Error)
+		}
+		token = login.Token
+	}
+
+	channelIDs := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		channelIDs = append(channelIDs, subscription.Snippet.ResourceId.ChannelId)
+	}
+
+	fmt.Printf("Importing %v channels into Piped\n", len(channelIDs))
+	header := http.Header{"Authorization": {token}}
+	if err := jsonRequest(ctx, http.MethodPost, api+"/import?override=false", header, channelIDs, nil); err != nil {
+		return err
+	}
+	fmt.Println("Successfully imported channels")
+
+	return nil
+}
+
+// pipedSubscriptions is the file Piped exports its subscriptions to and
+// imports them from, NewPipe's format marked as Piped's.
+type pipedSubscriptions struct {
+	Format        string                `json:"format"`
+	Version       int                   `json:"version"`
+	Subscriptions []newPipeSubscription `json:"subscriptions"`
+}
+
+// readPiped finds the channels in a Piped subscriptions export.
+func readPiped(file string, options importOptions) ([]channelReference, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var export pipedSubscriptions
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	if export.Format != "Piped" {
+		return nil, fmt.Errorf("the format is %q instead of Piped, is it a Piped subscriptions export?", export.Format)
+	}
+
+	var references []channelReference
+	for _, subscription := range export.Subscriptions {
+		if reference, ok := parseChannelURL(subscription.URL); ok {
+			reference.title = subscription.Name
+			references = append(references, reference)
+		}
+	}
+	return references, nil
+}
+
+// exportToPipedFile writes the channels as a Piped subscriptions export,
+// ready to be imported on a Piped instance's subscriptions page without
+// logging in from here.
+func exportToPipedFile(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	export := pipedSubscriptions{Format: "Piped", Version: 1, Subscriptions: make([]newPipeSubscription, 0, len(subscriptions))}
+	for _, subscription := range subscriptions {
+		export.Subscriptions = append(export.Subscriptions, newPipeSubscription{
+			ServiceID: newPipeYouTubeService,
+			URL:       channelURL(subscription.Snippet.ResourceId.ChannelId),
+			Name:      subscription.Snippet.Title,
+		})
+	}
+
+	return writeExport(options.outputOr("piped-subscriptions.json"), func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	})
+}
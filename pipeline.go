@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// targetAccountSink is the pipeline sink subscribing the target account to
+// the channels, any other sink is one of the exporters.
+const targetAccountSink = "target-account"
+
+// pipeline is a pipeline file: channels are read from the source, narrowed
+// down by each filter, changed by each transform in turn and written to the
+// sink.
+//
+//	source:
+//	  from: bookmarks
+//	  file: bookmarks.html
+//	filters:
+//	  - exclude-title: "(?i)podcast"
+//	transforms:
+//	  - channel-map: channel-map.txt
+//	sink:
+//	  to: target-account
+type pipeline struct {
+	Source     pipelineSource      `yaml:"source"`
+	Filters    []pipelineFilter    `yaml:"filters"`
+	Transforms []pipelineTransform `yaml:"transforms"`
+	Sink       pipelineSink        `yaml:"sink"`
+}
+
+// pipelineSource reads the channels: the source account's subscriptions if
+// From is account, listed again if Refresh is set, otherwise File read with
+// one of the importers, given Map like import's -map flags.
+type pipelineSource struct {
+	From    string   `yaml:"from"`
+	File    string   `yaml:"file"`
+	Map     []string `yaml:"map"`
+	Refresh bool     `yaml:"refresh"`
+}
+
+// pipelineFilter keeps the channels matching all of its set fields.
+type pipelineFilter struct {
+	IncludeTitle     string   `yaml:"include-title"`
+	ExcludeTitle     string   `yaml:"exclude-title"`
+	ExcludeChannels  []string `yaml:"exclude-channels"`
+	SubscribedAfter  string   `yaml:"subscribed-after"`
+	SubscribedBefore string   `yaml:"subscribed-before"`
+}
+
+// pipelineTransform changes the channels.
+type pipelineTransform struct {
+	ChannelMap string `yaml:"channel-map"`
+}
+
+// pipelineSink takes the channels: the target account, or one of the
+// exporters with the same settings as the export command's flags.
+type pipelineSink struct {
+	To         string `yaml:"to"`
+	URL        string `yaml:"url"`
+	Token      string `yaml:"token"`
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"`
+	Collection string `yaml:"collection"`
+	Backup     string `yaml:"backup"`
+	Output     string `yaml:"output"`
+	// StateFile is the target-account sink's state file, the usual one if
+	// empty
+	StateFile string `yaml:"state-file"`
+}
+
+func pipelineCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("pipeline", flag.ExitOnError)
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	addAuditLogFlag(flags)
+	schedule := addScheduleFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s pipeline [FLAGS] run FILE\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		flagsAndArgs := args
+		args = flags.Args()
+		if len(args) != 2 || args[0] != "run" {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		if *schedule != "" {
+			if err := runOnSchedule("pipeline", *schedule, flagsAndArgs); err != nil {
+				log.Fatalf("Unable to run on the schedule: %v", err)
+			}
+			return
+		}
+
+		p, err := readPipeline(args[1])
+		if err != nil {
+			log.Fatalf("Unable to read pipeline %s: %v", args[1], err)
+		}
+
+		ctx := context.Background()
+
+		var targetService *youtube.Service
+		if p.Sink.To == targetAccountSink {
+			targetService = getService(ctx, "target", youtube.YoutubeForceSslScope)
+		}
+
+		channels, err := p.Source.read(ctx, targetService)
+		if err != nil && len(channels) == 0 {
+			log.Fatalf("Unable to read source: %v", err)
+		} else if err != nil {
+			fmt.Printf("Unable to read all of the source, continuing with the %v channels read: %v\n", len(channels), err)
+		}
+		fmt.Printf("Read %s channels from the source\n", formatCount(len(channels)))
+
+		for _, filter := range p.Filters {
+			if channels, err = filter.apply(channels); err != nil {
+				log.Fatalf("Unable to filter channels: %v", err)
+			}
+		}
+		fmt.Printf("%s channels left after filtering\n", formatCount(len(channels)))
+
+		for _, transform := range p.Transforms {
+			if channels, err = transform.apply(channels); err != nil {
+				log.Fatalf("Unable to transform channels: %v", err)
+			}
+		}
+
+		if p.Sink.To != targetAccountSink {
+			if err := exporters[p.Sink.To](ctx, channels, p.Sink.exportOptions()); err != nil {
+				log.Fatalf("Unable to export to %s: %v", p.Sink.To, err)
+			}
+			return
+		}
+
+		if p.Sink.StateFile != "" {
+			stateFile = expandHome(p.Sink.StateFile)
+		}
+		if err := lookUpStateFileAccounts(ctx, func(account string) *youtube.Service {
+			if account == "source" {
+				return getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}
+			return targetService
+		}); err != nil {
+			log.Fatalf("Unable to look up the accounts to name the state file after: %v", err)
+		}
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			state = &importState{}
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+		fmt.Printf("Added %s new channels to the state file\n", formatCount(state.addChannels(channels)))
+
+		transferer := newTransferer(targetService, stateFile, state, transferOptions{
+			maxIdenticalFailures: defaultMaxIdenticalFailures,
+			auditLog:             newAuditLog(auditLogFile),
+			retries:              retries,
+		})
+		if _, err := transferer.Run(handleShutdown(func() {}), "pipeline "+args[1]); err != nil {
+			log.Fatalf("Unable to save state: %v", err)
+		}
+	}
+}
+
+// readPipeline decodes a pipeline file and checks its steps are known.
+func readPipeline(file string) (*pipeline, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+
+	p := &pipeline{}
+	if err := decoder.Decode(p); err != nil {
+		return nil, err
+	}
+
+	if _, ok := importers[p.Source.From]; !ok && p.Source.From != "account" {
+		return nil, fmt.Errorf("unknown source %q, expected account or one of: %s", p.Source.From, strings.Join(importerNames(), ", "))
+	}
+	if _, ok := exporters[p.Sink.To]; !ok && p.Sink.To != targetAccountSink {
+		return nil, fmt.Errorf("unknown sink %q, expected %s or one of: %s", p.Sink.To, targetAccountSink, strings.Join(exporterNames(), ", "))
+	}
+	return p, nil
+}
+
+// read returns the source's channels. Channels in files are looked up with
+// service, or the source account if nil.
+func (source pipelineSource) read(ctx context.Context, service *youtube.Service) ([]*youtube.Subscription, error) {
+	sourceService := func() *youtube.Service {
+		return getService(ctx, "source", youtube.YoutubeReadonlyScope)
+	}
+
+	if source.From == "account" {
+		return sourceSubscriptions(ctx, sourceService, source.Refresh)
+	}
+	if service == nil {
+		service = sourceService()
+	}
+
+	if source.File == "" {
+		return nil, errors.New("no file to read")
+	}
+	options := importOptions{}
+	for _, value := range source.Map {
+		mapping, err := parseFieldMapping(value)
+		if err != nil {
+			return nil, err
+		}
+		options.mapping = append(options.mapping, mapping)
+	}
+
+	references, err := importers[source.From](source.File, options)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, unresolved, err := resolveChannels(ctx, service, references)
+	for _, reference := range unresolved {
+		fmt.Printf("Unable to find channel %v %s\n", reference, reference.title)
+	}
+	return channels, err
+}
+
+func (filter pipelineFilter) apply(channels []*youtube.Subscription) ([]*youtube.Subscription, error) {
+	var include, exclude *regexp.Regexp
+	var err error
+	if filter.IncludeTitle != "" {
+		if include, err = regexp.Compile(filter.IncludeTitle); err != nil {
+			return nil, err
+		}
+	}
+	if filter.ExcludeTitle != "" {
+		if exclude, err = regexp.Compile(filter.ExcludeTitle); err != nil {
+			return nil, err
+		}
+	}
+	after, err := parseDate(filter.SubscribedAfter)
+	if err != nil {
+		return nil, err
+	}
+	before, err := parseDate(filter.SubscribedBefore)
+	if err != nil {
+		return nil, err
+	}
+	excludedChannels := make(map[string]bool)
+	for _, channelID := range filter.ExcludeChannels {
+		excludedChannels[channelID] = true
+	}
+
+	var kept []*youtube.Subscription
+	for _, channel := range channels {
+		title := channel.Snippet.Title
+		switch {
+		case include != nil && !include.MatchString(title):
+		case exclude != nil && exclude.MatchString(title):
+		case excludedChannels[channel.Snippet.ResourceId.ChannelId]:
+		case !subscribedWithin(channel, after, before):
+		default:
+			kept = append(kept, channel)
+		}
+	}
+	return kept, nil
+}
+
+func (transform pipelineTransform) apply(channels []*youtube.Subscription) ([]*youtube.Subscription, error) {
+	if transform.ChannelMap == "" {
+		return channels, nil
+	}
+
+	channelMap, err := readChannelMap(transform.ChannelMap)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range channels {
+		if newChannelID, ok := channelMap[channel.Snippet.ResourceId.ChannelId]; ok {
+			channel.Snippet.ResourceId.ChannelId = newChannelID
+		}
+	}
+	return channels, nil
+}
+
+func (sink pipelineSink) exportOptions() exportOptions {
+	options := exportOptions{
+		url:        sink.URL,
+		token:      sink.Token,
+		username:   sink.Username,
+		password:   sink.Password,
+		collection: sink.Collection,
+		backup:     sink.Backup,
+		output:     sink.Output,
+		readState: func() (*importState, error) {
+			return readStateFromFile(stateFile)
+		},
+	}
+	if options.collection == "" {
+		options.collection = "YouTube"
+	}
+	return options
+}
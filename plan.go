@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// plannedAction is an API call a transfer would make and its quota cost.
+type plannedAction struct {
+	index int
+	// channel is nil for calls not about a single channel
+	channel *youtube.Subscription
+	// channelID is the channel subscribed to, after remapping
+	channelID string
+	action    string
+	cost      int
+}
+
+// planTransfer returns the calls a transfer of the state would make with
+// the given options, in order: one subscribe call for each pending channel
+// within the chosen dates that isn't unavailable to the target account, up to
+// the limit and quota budget if there are. The quota used before the run
+// counts against the budget.
+func planTransfer(state *importState, options transferOptions) []plannedAction {
+	var actions []plannedAction
+	spent := options.quotaUsedBefore
+	for index, channelStatus := range state.Channels {
+		channel := channelStatus.Channel
+		if channelStatus.Imported || channelStatus.skipped() || !subscribedWithin(channel, options.subscribedAfter, options.subscribedBefore) {
+			continue
+		}
+
+		channelID := channel.Snippet.ResourceId.ChannelId
+		if newChannelID, ok := options.channelMap[channelID]; ok {
+			channelID = newChannelID
+		}
+
+		if options.limit > 0 && len(actions) >= options.limit {
+			break
+		}
+		if options.maxQuotaUnits > 0 && spent+subscriptionInsertCost > options.maxQuotaUnits {
+			break
+		}
+		spent += subscriptionInsertCost
+		actions = append(actions, plannedAction{
+			index:     index,
+			channel:   channel,
+			channelID: channelID,
+			action:    "subscribe",
+			cost:      subscriptionInsertCost,
+		})
+	}
+	return actions
+}
+
+// printPlan prints each planned action with its cost and the running total,
+// then how many days of quota the plan takes.
+func printPlan(actions []plannedAction, total, dailyQuota int) {
+	spent := 0
+	for _, action := range actions {
+		spent += action.cost
+		if action.channel == nil {
+			fmt.Printf("%s, %su, total %su\n", action.action, formatCount(action.cost), formatCount(spent))
+			continue
+		}
+		description := action.action
+		if action.channelID != action.channel.Snippet.ResourceId.ChannelId {
+			description += " (remapped to " + action.channelID + ")"
+		}
+		fmt.Printf("%s: %s: %s, %su, total %su\n", channelNumber(action.index, total), displayTitle(action.channel.Snippet.Title, titleWidth),
+			description, formatCount(action.cost), formatCount(spent))
+	}
+
+	days := 0
+	if dailyQuota > 0 {
+		days = (spent + dailyQuota - 1) / dailyQuota
+	}
+	fmt.Printf("%s calls costing %s quota units, about %s days at %s units a day\n",
+		formatCount(len(actions)), formatCount(spent), formatCount(days), formatCount(dailyQuota))
+}
+
+// printForecast prints the quota the channels left to transfer take, and
+// how many daily runs that is at dailyQuota, so it is clear up front how many
+// days the transfer spans.
+func printForecast(state *importState, options transferOptions, dailyQuota int) {
+	options.limit = 0
+	options.maxQuotaUnits = 0
+	units := 0
+	actions := planTransfer(state, options)
+	for _, action := range actions {
+		units += action.cost
+	}
+	if units == 0 {
+		return
+	}
+
+	runs, last := quotaRunsNeeded(units, dailyQuota, time.Now(), options.quotaResetLocation)
+	forecast := fmt.Sprintf("%s channels left to subscribe to, costing %s quota units: about %s daily runs at %s units a day",
+		formatCount(len(actions)), formatCount(units), formatCount(runs), formatCount(dailyQuota))
+	if runs > 1 {
+		forecast += fmt.Sprintf(", the last one on %s", formatDate(last.Local()))
+	}
+	fmt.Println(forecast)
+}
+
+// planCommand shows what the next transfer would do and what it would cost,
+// without calling the target account.
+func planCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	channelMapFile := flags.String("channel-map", "", "channel map file to use for the transfer")
+	subscribedAfter := flags.String("subscribed-after", "", "only plan channels subscribed to after this date")
+	subscribedBefore := flags.String("subscribed-before", "", "only plan channels subscribed to before this date")
+	dailyQuota := flags.Int("daily-quota", defaultDailyQuota, "daily quota units of the API project")
+	maxQuotaUnits := flags.Int("max-quota-units", 0, "only plan as many calls as fit in this many quota units (0 for no budget)")
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		options := transferOptions{channelMap: make(map[string]string), maxQuotaUnits: *maxQuotaUnits}
+		var err error
+		if *channelMapFile != "" {
+			if options.channelMap, err = readChannelMap(*channelMapFile); err != nil {
+				log.Fatalf("Unable to read channel map file: %v", err)
+			}
+		}
+		if options.subscribedAfter, err = parseDate(*subscribedAfter); err != nil {
+			log.Fatalf("Unable to parse -subscribed-after: %v", err)
+		}
+		if options.subscribedBefore, err = parseDate(*subscribedBefore); err != nil {
+			log.Fatalf("Unable to parse -subscribed-before: %v", err)
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			ctx := context.Background()
+			subscriptions, err := sourceSubscriptions(ctx, func() *youtube.Service {
+				return getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}, false)
+			if err != nil && len(subscriptions) == 0 {
+				log.Fatalf("Unable to list source channels: %v", err)
+			}
+			state = &importState{}
+			state.addChannels(subscriptions)
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		printPlan(planTransfer(state, options), len(state.Channels), *dailyQuota)
+		fmt.Println("A transfer first lists the target account's subscriptions, at 1 quota unit per 50, and skips the channels it already has, which this plan includes. Pass -dry-run to a transfer to leave them out")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+)
+
+// readPocketTube finds the channels in a PocketTube export, a JSON object
+// listing the channel IDs in each group by the group's name. The channels
+// are tagged with their groups. PocketTube's own settings are kept in keys
+// starting with ysc_, of which ysc_order orders the groups.
+func readPocketTube(file string, options importOptions) ([]channelReference, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var export map[string]json.RawMessage
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	var order []string
+	if raw, ok := export["ysc_order"]; ok {
+		json.Unmarshal(raw, &order)
+	}
+	ordered := make(map[string]bool)
+	var groups []string
+	for _, group := range order {
+		if _, ok := export[group]; ok && !ordered[group] {
+			ordered[group] = true
+			groups = append(groups, group)
+		}
+	}
+	var rest []string
+	for group := range export {
+		if !ordered[group] && !strings.HasPrefix(group, "ysc_") {
+			rest = append(rest, group)
+		}
+	}
+	sort.Strings(rest)
+	groups = append(groups, rest...)
+
+	var references []channelReference
+	index := make(map[string]int)
+	for _, group := range groups {
+		var channelIDs []string
+		if err := json.Unmarshal(export[group], &channelIDs); err != nil {
+			// Not a group
+			continue
+		}
+		for _, channelID := range channelIDs {
+			if !channelIDPattern.MatchString(channelID) {
+				continue
+			}
+			if i, ok := index[channelID]; ok {
+				references[i].tags = append(references[i].tags, group)
+				continue
+			}
+			index[channelID] = len(references)
+			references = append(references, channelReference{id: channelID, tags: []string{group}})
+		}
+	}
+	return references, nil
+}
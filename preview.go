@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// topicPreview counts the channels under a topic in previewTargetCommand.
+type topicPreview struct {
+	topic             string
+	current, new, all int
+}
+
+// previewTargetCommand shows what the target account's subscriptions will
+// look like once the transfer is done: how many it has now, how many of the
+// channels to transfer it already has, how many are new and the resulting
+// total, overall and by topic.
+func previewTargetCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("preview-target", flag.ExitOnError)
+	channelMapFile := flags.String("channel-map", "", "channel map file to use for the transfer")
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		channelMap := make(map[string]string)
+		if *channelMapFile != "" {
+			var err error
+			if channelMap, err = readChannelMap(*channelMapFile); err != nil {
+				log.Fatalf("Unable to read channel map file: %v", err)
+			}
+		}
+
+		ctx := context.Background()
+
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			subscriptions, err := sourceSubscriptions(ctx, func() *youtube.Service {
+				return getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}, false)
+			if err != nil && len(subscriptions) == 0 {
+				log.Fatalf("Unable to list source channels: %v", err)
+			}
+			state = &importState{}
+			state.addChannels(subscriptions)
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		targetService := getService(ctx, "target", youtube.YoutubeForceSslScope)
+		fmt.Println("Fetching target account subscriptions")
+		targetSubscriptions, err := mySubscriptions(ctx, targetService, []string{"snippet"})
+		if err != nil {
+			log.Fatalf("Unable to list target channels: %v", err)
+		}
+
+		current := make(map[string]bool)
+		for _, subscription := range targetSubscriptions {
+			current[subscription.Snippet.ResourceId.ChannelId] = true
+		}
+		transferred := make(map[string]bool)
+		for _, channelStatus := range state.Channels {
+			if channelStatus.skipped() {
+				continue
+			}
+			channelID := channelStatus.Channel.Snippet.ResourceId.ChannelId
+			if newChannelID, ok := channelMap[channelID]; ok {
+				channelID = newChannelID
+			}
+			transferred[channelID] = true
+		}
+
+		all := make([]string, 0, len(current)+len(transferred))
+		for channelID := range current {
+			all = append(all, channelID)
+		}
+		for channelID := range transferred {
+			if !current[channelID] {
+				all = append(all, channelID)
+			}
+		}
+
+		fmt.Println("Fetching channel topics")
+		topics, err := channelTopics(ctx, targetService, all)
+		if err != nil {
+			log.Fatalf("Unable to fetch channel topics: %v", err)
+		}
+
+		// Each channel is counted under its first topic only, so the topics add
+		// up to the totals
+		byTopic := make(map[string]*topicPreview)
+		total := &topicPreview{topic: "Total"}
+		for _, channelID := range all {
+			topic := "No topic"
+			if found := topics[channelID]; len(found) > 0 {
+				topic = found[0]
+			}
+			if byTopic[topic] == nil {
+				byTopic[topic] = &topicPreview{topic: topic}
+			}
+
+			for _, preview := range []*topicPreview{byTopic[topic], total} {
+				preview.all++
+				if current[channelID] {
+					preview.current++
+				} else {
+					preview.new++
+				}
+			}
+		}
+
+		shared := 0
+		for channelID := range transferred {
+			if current[channelID] {
+				shared++
+			}
+		}
+
+		previews := make([]*topicPreview, 0, len(byTopic))
+		for _, preview := range byTopic {
+			previews = append(previews, preview)
+		}
+		sort.Slice(previews, func(i, j int) bool {
+			if previews[i].all != previews[j].all {
+				return previews[i].all > previews[j].all
+			}
+			return previews[i].topic < previews[j].topic
+		})
+
+		fmt.Printf("The target account has %s subscriptions, %s of the %s channels to transfer are among them and %s are new, making %s in total\n",
+			formatCount(len(current)), formatCount(shared), formatCount(len(transferred)), formatCount(total.new), formatCount(total.all))
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Topic\tNow\tNew\tAfter\t")
+		for _, preview := range append(previews, total) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", preview.topic, formatCount(preview.current), formatCount(preview.new), formatCount(preview.all))
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// pruneCommand finds subscriptions to deleted or terminated channels, and
+// optionally to channels that haven't uploaded in a while, on one of the
+// accounts and unsubscribes from them once confirmed.
+func pruneCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	account := flags.String("account", "target", "account to prune, source or target")
+	inactiveFor := flags.String("inactive-for", "", "also prune channels that haven't uploaded for this long, e.g. 730d")
+	yes := flags.Bool("yes", false, "unsubscribe without asking for confirmation")
+	addAPIFlags(flags)
+	addAuditLogFlag(flags)
+	return flags, func(args []string) {
+		// The source account is normally only authorized to read, so it needs
+		// separate credentials allowed to unsubscribe
+		var kind string
+		switch *account {
+		case "target":
+			kind = "target"
+		case "source":
+			kind = "source-manage"
+		default:
+			log.Fatalf("Unknown account %q, expected source or target", *account)
+		}
+
+		inactive, err := parseRuleDuration(*inactiveFor)
+		if err != nil {
+			log.Fatalf("Unable to parse -inactive-for: %v", err)
+		}
+
+		ctx := context.Background()
+		service := getService(ctx, kind, youtube.YoutubeForceSslScope)
+
+		fmt.Printf("Fetching %s account subscriptions\n", *account)
+		subscriptions, err := mySubscriptions(ctx, service, []string{"snippet"})
+		if err != nil {
+			log.Fatalf("Unable to list %s channels: %v", *account, err)
+		}
+
+		channels, err := enrichChannels(ctx, service, subscriptions, inactive > 0)
+		if err != nil {
+			log.Fatalf("Unable to look up channel details: %v", err)
+		}
+
+		var prune []*youtube.Subscription
+		now := time.Now()
+		for _, channel := range channels {
+			reason := ""
+			switch {
+			case !channel.exists:
+				reason = "deleted or terminated"
+			case inactive > 0 && !channel.lastUploadKnown:
+				continue
+			case inactive > 0 && channel.lastUpload.IsZero():
+				reason = "no uploads"
+			case inactive > 0 && now.Sub(channel.lastUpload) > inactive:
+				reason = "last upload " + formatDate(channel.lastUpload)
+			default:
+				continue
+			}
+			fmt.Printf("  %s (%s)\n", channel.subscription.Snippet.Title, reason)
+			prune = append(prune, channel.subscription)
+		}
+
+		if len(prune) == 0 {
+			fmt.Println("Nothing to prune")
+			return
+		}
+		if !*yes && !confirm(fmt.Sprintf("Unsubscribe the %s account from these %s channels, using %s quota units?",
+			*account, formatCount(len(prune)), formatCount(len(prune)*subscriptionDeleteCost))) {
+			return
+		}
+
+		audit := newAuditLog(auditLogFile)
+		for _, subscription := range prune {
+			line := startStatusLine(fmt.Sprintf("Unsubscribing from %s: ", displayTitle(subscription.Snippet.Title, titleWidth)))
+			err := service.Subscriptions.Delete(subscription.Id).Context(ctx).Do()
+			recordUnsubscribe(audit, *account, subscription, err)
+			if err != nil {
+				line.finish(colorRed, fmt.Sprintf("stopping with error: %v", err))
+				return
+			}
+			line.finish(colorGreen, "unsubscribed")
+		}
+	}
+}
+
+// confirm asks a yes or no question on the terminal, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// querySchema is the tables the query command loads the channels and runs
+// into. topic is the channel's first topic, the topics table has them all.
+const querySchema = `
+CREATE TABLE subscriptions (
+	channel_id TEXT PRIMARY KEY,
+	title TEXT,
+	description TEXT,
+	subscribed_at TEXT,
+	imported INTEGER,
+	topic TEXT,
+	subscribers INTEGER,
+	last_upload TEXT
+);
+CREATE TABLE topics (channel_id TEXT, topic TEXT);
+CREATE TABLE runs (
+	label TEXT,
+	started TEXT,
+	finished TEXT,
+	imported INTEGER,
+	failed INTEGER,
+	quota_used INTEGER,
+	quota_exceeded INTEGER
+);
+`
+
+// queryCommand runs SQL against the channels in the state file, or the
+// source account's subscriptions if there is none yet, and the recorded
+// runs, loaded into an in-memory SQLite database.
+func queryCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	enrich := flags.Bool("enrich", false, "look up each channel's topics, subscriber count and last upload to fill in those columns, which costs quota")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s query [-enrich] SQL\n\nTables:%s", os.Args[0], querySchema)
+		flags.PrintDefaults()
+	}
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	return flags, func(args []string) {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		ctx := context.Background()
+		var service *youtube.Service
+		sourceService := func() *youtube.Service {
+			if service == nil {
+				service = getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}
+			return service
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			state = &importState{}
+			subscriptions, err := sourceSubscriptions(ctx, sourceService, false)
+			if err != nil && len(subscriptions) == 0 {
+				log.Fatalf("Unable to list source channels: %v", err)
+			}
+			state.addChannels(subscriptions)
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		var channels []enrichedChannel
+		if *enrich {
+			subscriptions := make([]*youtube.Subscription, 0, len(state.Channels))
+			for _, channelStatus := range state.Channels {
+				subscriptions = append(subscriptions, channelStatus.Channel)
+			}
+			if channels, err = enrichChannels(ctx, sourceService(), subscriptions, true); err != nil {
+				log.Fatalf("Unable to look up channel details: %v", err)
+			}
+		} else {
+			for _, channelStatus := range state.Channels {
+				channels = append(channels, enrichedChannel{subscription: channelStatus.Channel})
+			}
+		}
+
+		db, err := loadQueryDatabase(":memory:", state, channels)
+		if err != nil {
+			log.Fatalf("Unable to load the channels: %v", err)
+		}
+		defer db.Close()
+
+		if err := printQuery(db, flags.Arg(0)); err != nil {
+			log.Fatalf("Query failed: %v", err)
+		}
+	}
+}
+
+// loadQueryDatabase creates a database with querySchema holding the
+// channels, in the same order as the state's, and runs, in memory for
+// :memory: or in a new database file.
+func loadQueryDatabase(dataSource string, state *importState, channels []enrichedChannel) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dataSource)
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to :memory: is a separate database, and the rows
+	// are inserted in a transaction on one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(querySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec("BEGIN"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for i, channel := range channels {
+		snippet := channel.subscription.Snippet
+		channelID := snippet.ResourceId.ChannelId
+
+		var topic, subscribers, lastUpload interface{}
+		if len(channel.topics) > 0 {
+			topic = channel.topics[0]
+		}
+		if channel.subscribersKnown {
+			subscribers = channel.subscribers
+		}
+		if !channel.lastUpload.IsZero() {
+			lastUpload = channel.lastUpload.UTC().Format("2006-01-02 15:04:05")
+		}
+
+		if _, err := db.Exec("INSERT OR IGNORE INTO subscriptions VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			channelID, snippet.Title, snippet.Description, sqliteTime(snippet.PublishedAt), state.Channels[i].Imported, topic, subscribers, lastUpload); err != nil {
+			db.Close()
+			return nil, err
+		}
+		for _, topic := range channel.topics {
+			if _, err := db.Exec("INSERT INTO topics VALUES (?, ?)", channelID, topic); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+	}
+
+	for _, run := range state.Runs {
+		if _, err := db.Exec("INSERT INTO runs VALUES (?, ?, ?, ?, ?, ?, ?)",
+			run.Label, run.Started.UTC().Format("2006-01-02 15:04:05"), run.Finished.UTC().Format("2006-01-02 15:04:05"),
+			run.Imported, run.Failed, run.QuotaUsed, run.QuotaExceeded); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if _, err := db.Exec("COMMIT"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// sqliteTime turns an API timestamp into the format SQLite's date functions
+// compare with, or NULL if there is none.
+func sqliteTime(timestamp string) interface{} {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// printQuery runs a query and prints the rows as aligned columns.
+func printQuery(db *sql.DB, query string) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		fields := make([]string, len(values))
+		for i, value := range values {
+			if value == nil {
+				fields[i] = "NULL"
+			} else {
+				fields[i] = fmt.Sprint(value)
+			}
+		}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// markExportedImported marks the channels of state the state file has
+// imported, and copies its runs. A missing state file marks none.
+func markExportedImported(state *importState, readState func() (*importState, error)) error {
+	transferState, err := readState()
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read state file: %v", err)
+	}
+
+	imported := make(map[string]bool)
+	for _, channelStatus := range transferState.Channels {
+		imported[channelStatus.Channel.Snippet.ResourceId.ChannelId] = channelStatus.Imported
+	}
+	for index := range state.Channels {
+		state.Channels[index].Imported = imported[state.Channels[index].Channel.Snippet.ResourceId.ChannelId]
+	}
+	state.Runs = transferState.Runs
+	return nil
+}
+
+// exportToSQLite writes the channels and the recorded runs to a SQLite
+// database with the tables the query command queries, marking the channels
+// the state file has imported.
+func exportToSQLite(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	state := &importState{}
+	state.addChannels(subscriptions)
+	if options.readState != nil {
+		if err := markExportedImported(state, options.readState); err != nil {
+			return err
+		}
+	}
+
+	channels := make([]enrichedChannel, 0, len(state.Channels))
+	for _, channelStatus := range state.Channels {
+		channels = append(channels, enrichedChannel{subscription: channelStatus.Channel})
+	}
+
+	output := options.outputOr("subscriptions.db")
+	// The tables are created afresh
+	if err := os.Remove(output); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := loadQueryDatabase(output, state, channels)
+	if err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote subscriptions to %s\n", output)
+	return nil
+}
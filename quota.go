@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	// Embedded so the quota reset time zone can be loaded on machines
+	// without a time zone database, such as Windows
+	_ "time/tzdata"
+)
+
+// defaultQuotaResetTimeZone is the time zone the YouTube API's daily quota
+// resets at midnight in.
+const defaultQuotaResetTimeZone = "America/Los_Angeles"
+
+// nextQuotaReset returns when the daily quota next resets: the first
+// midnight after now in the quota's time zone, whatever the local time zone
+// is. Daylight saving time is accounted for by the time zone.
+func nextQuotaReset(now time.Time, location *time.Location) time.Time {
+	local := now.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, location)
+}
+
+// quotaRunsNeeded returns how many daily runs spending dailyQuota units it
+// takes to spend units, and the day the last one would run if one runs
+// every quota day starting now.
+func quotaRunsNeeded(units, dailyQuota int, now time.Time, location *time.Location) (int, time.Time) {
+	if units <= 0 || dailyQuota <= 0 {
+		return 0, now
+	}
+	runs := (units + dailyQuota - 1) / dailyQuota
+	last := now
+	for run := 1; run < runs; run++ {
+		last = nextQuotaReset(last, location)
+	}
+	return runs, last
+}
+
+// quotaResetMargin is how long after midnight a transfer waiting for the
+// quota to reset resumes, as the reset isn't always on the minute.
+const quotaResetMargin = 5 * time.Minute
+
+// waitForQuotaReset sleeps until shortly after the daily quota next resets.
+// It returns false if ctx is done first.
+func waitForQuotaReset(ctx context.Context, location *time.Location) bool {
+	now := time.Now()
+	resume := nextQuotaReset(now, location).Add(quotaResetMargin)
+	fmt.Printf("Waiting for the quota to reset, resuming at %s (in %v). Press Ctrl+C to stop\n",
+		formatDateTime(resume.Local())+resume.Local().Format(" MST"), resume.Sub(now).Round(time.Minute))
+
+	timer := time.NewTimer(resume.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
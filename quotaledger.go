@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// defaultDailyQuota is the daily quota of a new API project.
+const defaultDailyQuota = 10000
+
+// quotaLedgerLockTimeout is how long a ledger lock file is honoured before
+// it is taken to be left behind by a crashed instance.
+const quotaLedgerLockTimeout = 30 * time.Second
+
+var errQuotaBudgetSpent = errors.New("the shared daily quota budget has been spent")
+
+// quotaLedgerEntry is the content of a ledger file: the quota units used so
+// far on the quota day.
+type quotaLedgerEntry struct {
+	Day  string `json:"day"`
+	Used int    `json:"used"`
+}
+
+// quotaLedger is a file recording the quota spent on the current quota day
+// by every instance sharing an API project. Each instance reserves units in
+// it before calling the API, so together they stay within the daily budget
+// instead of tripping over each other.
+type quotaLedger struct {
+	file     string
+	limit    int
+	location *time.Location
+	clock    func() time.Time
+}
+
+func newQuotaLedger(file string, limit int, location *time.Location) *quotaLedger {
+	return &quotaLedger{file: file, limit: limit, location: location, clock: time.Now}
+}
+
+// reserve records units as spent, or returns errQuotaBudgetSpent if that
+// would take the quota day over the limit.
+func (ledger *quotaLedger) reserve(units int) error {
+	unlock, err := ledger.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entry, err := ledger.read()
+	if err != nil {
+		return err
+	}
+
+	if day := ledger.clock().In(ledger.location).Format("2006-01-02"); entry.Day != day {
+		entry = quotaLedgerEntry{Day: day}
+	}
+	if entry.Used+units > ledger.limit {
+		return errQuotaBudgetSpent
+	}
+	entry.Used += units
+
+	return writeFileAtomically(ledger.file, 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(entry)
+	})
+}
+
+func (ledger *quotaLedger) read() (quotaLedgerEntry, error) {
+	entry := quotaLedgerEntry{}
+
+	data, err := os.ReadFile(ledger.file)
+	if os.IsNotExist(err) {
+		return entry, nil
+	} else if err != nil {
+		return entry, err
+	}
+
+	return entry, json.Unmarshal(data, &entry)
+}
+
+// lock creates a lock file next to the ledger, waiting while another
+// instance holds it, and returns a function removing it.
+func (ledger *quotaLedger) lock() (func(), error) {
+	lockFile := ledger.file + ".lock"
+	deadline := time.Now().Add(quotaLedgerLockTimeout)
+
+	for {
+		f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockFile) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockFile); err == nil && time.Since(info.ModTime()) > quotaLedgerLockTimeout {
+			os.Remove(lockFile)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", lockFile)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
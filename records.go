@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// channelIDPattern matches a bare channel ID.
+var channelIDPattern = regexp.MustCompile(`^UC[0-9A-Za-z_-]{22}$`)
+
+// importFields are the fields -map can say where to find in a record.
+var importFields = []string{"channel_id", "url", "handle", "username", "title"}
+
+// fieldMapping says where a field is in each record of a CSV or JSON file:
+// the column'th column, counting from 1, or the column or JSON key called
+// name. Nested JSON keys are joined with dots, e.g. snippet.title.
+type fieldMapping struct {
+	field  string
+	column int
+	name   string
+}
+
+// parseFieldMapping parses a -map value, FIELD=col:N or FIELD=NAME.
+func parseFieldMapping(value string) (fieldMapping, error) {
+	field, location, ok := strings.Cut(value, "=")
+	if !ok || location == "" {
+		return fieldMapping{}, fmt.Errorf("expected FIELD=col:N or FIELD=NAME, got %q", value)
+	}
+
+	known := false
+	for _, importField := range importFields {
+		known = known || field == importField
+	}
+	if !known {
+		return fieldMapping{}, fmt.Errorf("unknown field %q, expected one of: %s", field, strings.Join(importFields, ", "))
+	}
+
+	if number, ok := strings.CutPrefix(location, "col:"); ok {
+		column, err := strconv.Atoi(number)
+		if err != nil || column < 1 {
+			return fieldMapping{}, fmt.Errorf("expected a column number from 1 in %q", value)
+		}
+		return fieldMapping{field: field, column: column}, nil
+	}
+	return fieldMapping{field: field, name: location}, nil
+}
+
+// record is a row of a CSV file or an object in a JSON file: its values in
+// order and by column name or JSON key.
+type record struct {
+	values []string
+	named  map[string]string
+}
+
+func (r record) lookUp(mapping fieldMapping) string {
+	if mapping.column > 0 {
+		if mapping.column > len(r.values) {
+			return ""
+		}
+		return r.values[mapping.column-1]
+	}
+	return r.named[mapping.name]
+}
+
+// channelReferences finds the channel in each record, using the mapping if
+// given and otherwise any value that is a channel link or ID. Records
+// without a channel, such as a header row, are skipped.
+func channelReferences(records []record, mapping []fieldMapping) []channelReference {
+	var references []channelReference
+	for _, r := range records {
+		reference, found := channelReference{}, false
+
+		if len(mapping) == 0 {
+			for _, value := range r.values {
+				if reference, found = referenceFromField("url", value); found {
+					break
+				}
+			}
+		}
+
+		title := ""
+		for _, m := range mapping {
+			value := strings.TrimSpace(r.lookUp(m))
+			if m.field == "title" {
+				title = value
+			} else if !found {
+				reference, found = referenceFromField(m.field, value)
+			}
+		}
+
+		if found {
+			reference.title = title
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// referenceFromField interprets a value as the given field. Channel links
+// and bare channel IDs are accepted for both channel_id and url.
+func referenceFromField(field, value string) (channelReference, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return channelReference{}, false
+	}
+
+	switch field {
+	case "channel_id", "url":
+		if reference, ok := parseChannelURL(value); ok {
+			return reference, true
+		}
+		if channelIDPattern.MatchString(value) {
+			return channelReference{id: value}, true
+		}
+	case "handle":
+		return channelReference{handle: strings.TrimPrefix(value, "@")}, true
+	case "username":
+		return channelReference{username: value}, true
+	}
+	return channelReference{}, false
+}
+
+// readCSV finds the channels in a CSV file. Column names given in the
+// mapping are looked up in the first row.
+func readCSV(file string, options importOptions) ([]channelReference, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var header []string
+	for _, m := range options.mapping {
+		if m.name != "" && len(rows) > 0 {
+			header, rows = rows[0], rows[1:]
+			break
+		}
+	}
+
+	records := make([]record, 0, len(rows))
+	for _, row := range rows {
+		r := record{values: row, named: make(map[string]string)}
+		for i, name := range header {
+			if i < len(row) {
+				r.named[strings.TrimSpace(name)] = row[i]
+			}
+		}
+		records = append(records, r)
+	}
+
+	return channelReferences(records, options.mapping), nil
+}
+
+// readJSON finds the channels in a JSON file holding an array of objects,
+// either at the top or as the value of a key of the top object.
+func readJSON(file string, options importOptions) ([]channelReference, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	items, ok := document.([]interface{})
+	if object, isObject := document.(map[string]interface{}); isObject {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if items, ok = object[key].([]interface{}); ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, errors.New("no array of records found")
+	}
+
+	records := make([]record, 0, len(items))
+	for _, item := range items {
+		r := record{named: make(map[string]string)}
+		flattenJSON("", item, r.named)
+
+		keys := make([]string, 0, len(r.named))
+		for key := range r.named {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			r.values = append(r.values, r.named[key])
+		}
+		records = append(records, r)
+	}
+
+	return channelReferences(records, options.mapping), nil
+}
+
+// flattenJSON adds the values in a JSON value to values keyed by their
+// dotted paths, e.g. snippet.resourceId.channelId.
+func flattenJSON(path string, value interface{}, values map[string]string) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range value {
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenJSON(key, nested, values)
+		}
+	case []interface{}:
+		for i, nested := range value {
+			flattenJSON(fmt.Sprintf("%s.%d", path, i), nested, values)
+		}
+	case string:
+		values[path] = value
+	case nil:
+	default:
+		values[path] = fmt.Sprint(value)
+	}
+}
+
+// readText finds the channels in a text file listing one per line, as a
+// link, a channel ID or an @handle. Blank lines and lines starting with #
+// are skipped, other lines not naming a channel are reported.
+func readText(file string, options importOptions) ([]channelReference, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var references []channelReference
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		link := line
+		if !strings.Contains(link, "://") {
+			// Links are often copied without the scheme
+			link = "https://" + link
+		}
+		if reference, ok := parseChannelURL(link); ok {
+			references = append(references, reference)
+		} else if channelIDPattern.MatchString(line) {
+			references = append(references, channelReference{id: line})
+		} else if strings.HasPrefix(line, "@") && len(line) > 1 && !strings.ContainsAny(line, " /") {
+			references = append(references, channelReference{handle: strings.TrimPrefix(line, "@")})
+		} else {
+			fmt.Printf("Line %d isn't a channel link, ID or handle, skipping it: %s\n", number, line)
+		}
+	}
+	return references, scanner.Err()
+}
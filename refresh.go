@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// refreshCommand lists the source account's subscriptions, or reads the
+// -source sources, again and adds the channels missing from the state file
+// as pending, leaving the channels already in it as they are, so channels
+// subscribed to on the source account since the transfer started are
+// transferred too.
+func refreshCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("refresh", flag.ExitOnError)
+	rulesFile := flags.String("rules", "", "rules file deciding which of the new source subscriptions to add")
+	sourceValues := addSourceFlag(flags)
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	schedule := addScheduleFlag(flags)
+	return flags, func(args []string) {
+		if *schedule != "" {
+			if err := runOnSchedule("refresh", *schedule, args); err != nil {
+				log.Fatalf("Unable to run on the schedule: %v", err)
+			}
+			return
+		}
+
+		sources, err := parseTransferSources(*sourceValues)
+		if err != nil {
+			log.Fatalf("Invalid -source: %v", err)
+		}
+
+		state, err := readStateFromFile(stateFile)
+		if os.IsNotExist(err) {
+			log.Fatalf("There is no state file to refresh yet, run the transfer to start one")
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		ctx := context.Background()
+		// The accounts are only authorized if the sources need them
+		var sourceService, targetService *youtube.Service
+		source := func() *youtube.Service {
+			if sourceService == nil {
+				sourceService = getService(ctx, "source", youtube.YoutubeReadonlyScope)
+				// With -source-channel-id the credentials are the target
+				// account's
+				if sourceChannelID == "" {
+					if err := checkAccountChannel(ctx, sourceService, state, "source"); err != nil {
+						log.Fatalf("Unable to use the source account: %v", err)
+					}
+				}
+			}
+			return sourceService
+		}
+		target := func() *youtube.Service {
+			if targetService == nil {
+				targetService = getService(ctx, "target", youtube.YoutubeForceSslScope)
+			}
+			return targetService
+		}
+
+		channels, tags, err := readTransferSources(ctx, sources, source, target, true)
+		if errors.Is(err, errIncompleteSources) {
+			fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: %v. Adding the new channels among the %s listed, the next transfer lists the sources again to add the rest",
+				err, formatCount(len(channels)))))
+		} else if err != nil {
+			log.Fatalf("Unable to list source channels: %v", err)
+		}
+		state.SourcesIncomplete = err != nil
+
+		listed := make(map[string]bool)
+		for _, channel := range channels {
+			listed[channel.Snippet.ResourceId.ChannelId] = true
+		}
+		known := make(map[string]bool)
+		gone := 0
+		for _, channelStatus := range state.Channels {
+			channelID := channelStatus.Channel.Snippet.ResourceId.ChannelId
+			known[channelID] = true
+			if !listed[channelID] {
+				gone++
+			}
+		}
+
+		// Only the new channels are looked at, the rest are already decided
+		var newChannels []*youtube.Subscription
+		for _, channel := range channels {
+			if !known[channel.Snippet.ResourceId.ChannelId] {
+				newChannels = append(newChannels, channel)
+			}
+		}
+		channels = newChannels
+
+		if *rulesFile != "" {
+			rules, err := readRules(*rulesFile)
+			if err != nil {
+				log.Fatalf("Unable to read rules %s: %v", *rulesFile, err)
+			}
+			lookup := target
+			if usesSourceAccount(sources) {
+				lookup = source
+			}
+			decisions, err := applyRules(ctx, lookup(), rules, channels)
+			if err != nil {
+				log.Fatalf("Unable to look up channel details: %v", err)
+			}
+			channels = keptChannels(decisions)
+		}
+
+		added := state.addChannels(channels)
+		state.tagChannels(tags)
+		if err := writeStateToFile(stateFile, state); err != nil {
+			log.Fatalf("Unable to save state: %v", err)
+		}
+
+		fmt.Printf("Added %s new channels, run the transfer to subscribe the target account to them\n", formatCount(added))
+		// Channels missing from a partial listing may well still be subscribed to
+		if gone > 0 && !state.SourcesIncomplete {
+			fmt.Printf("%s channels in the state file aren't among the source subscriptions anymore, they are left as they are\n", formatCount(gone))
+		}
+	}
+}
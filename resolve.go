@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// channelReference is a channel as referred to in an imported file: by ID,
+// by handle, by legacy username or by custom URL name. Exactly one is set.
+type channelReference struct {
+	id         string
+	handle     string
+	username   string
+	customName string
+
+	// title is the name the file gave the channel, if any
+	title string
+	// tags are the groups the file put the channel in, if any
+	tags []string
+}
+
+func (reference channelReference) String() string {
+	switch {
+	case reference.id != "":
+		return reference.id
+	case reference.handle != "":
+		return "@" + reference.handle
+	case reference.username != "":
+		return "user/" + reference.username
+	default:
+		return "c/" + reference.customName
+	}
+}
+
+// parseChannelURL recognizes the ways YouTube links to a channel, such as
+// https://www.youtube.com/channel/UC..., /@handle, /user/name, /c/name and
+// the channel's RSS feed. It reports false for any other URL.
+func parseChannelURL(rawURL string) (channelReference, bool) {
+	link, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return channelReference{}, false
+	}
+
+	host := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(link.Host), "www."), "m.")
+	if host != "youtube.com" && host != "music.youtube.com" {
+		return channelReference{}, false
+	}
+
+	segments := strings.Split(strings.Trim(link.Path, "/"), "/")
+	if channelID := link.Query().Get("channel_id"); segments[0] == "feeds" && channelID != "" {
+		return channelReference{id: channelID}, true
+	}
+	if strings.HasPrefix(segments[0], "@") && len(segments[0]) > 1 {
+		return channelReference{handle: strings.TrimPrefix(segments[0], "@")}, true
+	}
+	if len(segments) < 2 || segments[1] == "" {
+		return channelReference{}, false
+	}
+
+	switch segments[0] {
+	case "channel":
+		return channelReference{id: segments[1]}, true
+	case "user":
+		return channelReference{username: segments[1]}, true
+	case "c":
+		return channelReference{customName: segments[1]}, true
+	}
+
+	return channelReference{}, false
+}
+
+// resolveChannels looks up the referenced channels, returning them as
+// subscriptions ready to be imported along with the references that don't
+// point to an existing channel. Duplicates are only returned once.
+func resolveChannels(ctx context.Context, service *youtube.Service, references []channelReference) ([]*youtube.Subscription, []channelReference, error) {
+	var unresolved []channelReference
+
+	// Channel IDs are looked up in batches, the rest have to be looked up one
+	// by one
+	channelIDs := make([]string, 0, len(references))
+	for _, reference := range references {
+		if reference.id != "" {
+			channelIDs = append(channelIDs, reference.id)
+			continue
+		}
+
+		fmt.Printf("Resolving %v: ", reference)
+		channel, err := lookUpChannel(ctx, service, reference)
+		if err != nil {
+			return nil, nil, err
+		}
+		if channel == nil {
+			fmt.Printf("not found\n")
+			unresolved = append(unresolved, reference)
+			continue
+		}
+		fmt.Printf("%s\n", channel.Id)
+		channelIDs = append(channelIDs, channel.Id)
+	}
+
+	channels, err := channelsByID(ctx, service, channelIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, reference := range references {
+		if reference.id != "" && channels[reference.id] == nil {
+			unresolved = append(unresolved, reference)
+		}
+	}
+
+	subscriptions := make([]*youtube.Subscription, 0, len(channelIDs))
+	seen := make(map[string]bool)
+	for _, channelID := range channelIDs {
+		channel := channels[channelID]
+		if channel == nil || seen[channelID] {
+			continue
+		}
+		seen[channelID] = true
+
+		subscriptions = append(subscriptions, &youtube.Subscription{
+			Snippet: &youtube.SubscriptionSnippet{
+				Title:       channel.Snippet.Title,
+				Description: channel.Snippet.Description,
+				Thumbnails:  channel.Snippet.Thumbnails,
+				ResourceId: &youtube.ResourceId{
+					ChannelId: channelID,
+					Kind:      "youtube#channel",
+				},
+			},
+		})
+	}
+
+	return subscriptions, unresolved, nil
+}
+
+// lookUpChannel finds the channel a handle, username or custom name refers
+// to, returning nil if there is none. Custom URLs can't be looked up
+// directly, but mostly match the channel's handle or legacy username.
+func lookUpChannel(ctx context.Context, service *youtube.Service, reference channelReference) (*youtube.Channel, error) {
+	var calls []*youtube.ChannelsListCall
+	switch {
+	case reference.handle != "":
+		calls = append(calls, service.Channels.List([]string{"id"}).ForHandle(reference.handle))
+	case reference.username != "":
+		calls = append(calls, service.Channels.List([]string{"id"}).ForUsername(reference.username))
+	case reference.customName != "":
+		calls = append(calls,
+			service.Channels.List([]string{"id"}).ForHandle(reference.customName),
+			service.Channels.List([]string{"id"}).ForUsername(reference.customName))
+	}
+
+	for _, call := range calls {
+		response, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Items) > 0 {
+			return response.Items[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// channelsByID fetches the snippets of channels, keyed by channel ID.
+// Channels that don't exist are missing from the result.
+func channelsByID(ctx context.Context, service *youtube.Service, channelIDs []string) (map[string]*youtube.Channel, error) {
+	channels := make(map[string]*youtube.Channel)
+
+	for start := 0; start < len(channelIDs); start += maxChannelsPerRequest {
+		end := start + maxChannelsPerRequest
+		if end > len(channelIDs) {
+			end = len(channelIDs)
+		}
+
+		response, err := service.Channels.List([]string{"snippet"}).Id(channelIDs[start:end]...).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range response.Items {
+			channels[channel.Id] = channel
+		}
+	}
+
+	return channels, nil
+}
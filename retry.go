@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// retryPolicy is how API calls failing for reasons that may go away, such
+// as server errors and rate limiting, are tried again.
+type retryPolicy struct {
+	// attempts is how many times a call is made at most
+	attempts int
+	// delay is the wait before the first retry, doubled for every further
+	// one up to maxDelay
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+// retries is the policy API calls are retried with, set with -retries,
+// -retry-delay and -retry-max-delay.
+var retries = retryPolicy{attempts: 5, delay: time.Second, maxDelay: time.Minute}
+
+// addRetryFlags adds the flags setting the retry policy.
+func addRetryFlags(flags *flag.FlagSet) {
+	flags.IntVar(&retries.attempts, "retries", retries.attempts, "how many times an API call failing with a server error or rate limiting is made at most")
+	flags.DurationVar(&retries.delay, "retry-delay", retries.delay, "wait before retrying a failed API call, doubled for every further retry")
+	flags.DurationVar(&retries.maxDelay, "retry-max-delay", retries.maxDelay, "longest wait between retries of a failed API call")
+}
+
+// backoff returns how long to wait before the given retry, counting from 1:
+// the delay doubled for every earlier retry, capped at maxDelay, of which a
+// random half is left out so instances failing together don't retry
+// together.
+func (policy retryPolicy) backoff(retry int) time.Duration {
+	delay := policy.delay
+	for i := 1; i < retry && delay < policy.maxDelay; i++ {
+		delay *= 2
+	}
+	if policy.maxDelay > 0 && delay > policy.maxDelay {
+		delay = policy.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// do makes call until it succeeds, fails in a way that isn't retryable or
+// has been made policy.attempts times, waiting between attempts unless ctx
+// is done. retrying, if not nil, is told about each retry before waiting.
+// It returns how many times call was made and its last error.
+func (policy retryPolicy) do(ctx context.Context, call func() error, retrying func(err error, delay time.Duration)) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= policy.attempts || !isRetryable(err) {
+			return attempt, err
+		}
+
+		delay := policy.backoff(attempt)
+		if retrying != nil {
+			retrying(err, delay)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return attempt, err
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleSet is a rules file deciding which channels to keep. Each channel is
+// matched against the rules in order and the first matching rule's action
+// applies, channels no rule matches get the default action, keep unless set.
+//
+//	default: keep
+//	rules:
+//	  - action: drop
+//	    title: "(?i)podcast"
+//	  - action: drop
+//	    inactive-for: 365d
+//	  - action: route
+//	    route: Gaming
+//	    topic: Video game culture
+//	  - action: keep
+//	    min-subscribers: 100000
+type ruleSet struct {
+	Default string `yaml:"default"`
+	Rules   []rule `yaml:"rules"`
+}
+
+// rule matches the channels meeting all of its set conditions. Its action is
+// keep, drop or route, which keeps the channel and files it under Route when
+// exporting.
+type rule struct {
+	Name   string `yaml:"name"`
+	Action string `yaml:"action"`
+	Route  string `yaml:"route"`
+
+	Title          string `yaml:"title"`
+	Topic          string `yaml:"topic"`
+	MinSubscribers uint64 `yaml:"min-subscribers"`
+	MaxSubscribers uint64 `yaml:"max-subscribers"`
+	// InactiveFor and ActiveWithin are durations since the last upload
+	InactiveFor  string `yaml:"inactive-for"`
+	ActiveWithin string `yaml:"active-within"`
+
+	title                     *regexp.Regexp
+	inactiveFor, activeWithin time.Duration
+}
+
+// enrichedChannel is a subscription along with the details of its channel
+// the rules can match on.
+type enrichedChannel struct {
+	subscription *youtube.Subscription
+	// exists is false for deleted and terminated channels
+	exists bool
+	topics []string
+	// subscribers is only known if the channel shows its subscriber count
+	subscribers      uint64
+	subscribersKnown bool
+	// lastUpload is only known if a rule matches on activity, and the
+	// channel's uploads could be looked up
+	lastUpload      time.Time
+	lastUploadKnown bool
+}
+
+// ruleDecision is what the rules decided for a channel and why.
+type ruleDecision struct {
+	channel *youtube.Subscription
+	keep    bool
+	route   string
+	reason  string
+}
+
+func (decision ruleDecision) String() string {
+	action := "drop"
+	if decision.route != "" {
+		action = "route to " + decision.route
+	} else if decision.keep {
+		action = "keep"
+	}
+	return fmt.Sprintf("%s: %s (%s)", action, decision.channel.Snippet.Title, decision.reason)
+}
+
+// readRules decodes a rules file and checks its rules.
+func readRules(file string) (*ruleSet, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+
+	rules := &ruleSet{}
+	if err := decoder.Decode(rules); err != nil {
+		return nil, err
+	}
+
+	if rules.Default == "" {
+		rules.Default = "keep"
+	}
+	if rules.Default != "keep" && rules.Default != "drop" {
+		return nil, fmt.Errorf("unknown default %q, expected keep or drop", rules.Default)
+	}
+
+	for i := range rules.Rules {
+		r := &rules.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		switch r.Action {
+		case "keep", "drop":
+		case "route":
+			if r.Route == "" {
+				return nil, fmt.Errorf("%s: route action without a route", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q, expected keep, drop or route", r.Name, r.Action)
+		}
+
+		if r.Title != "" {
+			if r.title, err = regexp.Compile(r.Title); err != nil {
+				return nil, fmt.Errorf("%s: %v", r.Name, err)
+			}
+		}
+		if r.inactiveFor, err = parseRuleDuration(r.InactiveFor); err != nil {
+			return nil, fmt.Errorf("%s: inactive-for: %v", r.Name, err)
+		}
+		if r.activeWithin, err = parseRuleDuration(r.ActiveWithin); err != nil {
+			return nil, fmt.Errorf("%s: active-within: %v", r.Name, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// parseRuleDuration parses a Go duration, also accepting whole days such as
+// 365d. Empty is zero.
+func parseRuleDuration(duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(duration, "d")); err == nil && strings.HasSuffix(duration, "d") {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(duration)
+}
+
+// matchesActivity reports whether any rule matches on the last upload, which
+// costs an extra call per channel to find out.
+func (rules *ruleSet) matchesActivity() bool {
+	for _, r := range rules.Rules {
+		if r.inactiveFor > 0 || r.activeWithin > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// decide matches a channel against the rules.
+func (rules *ruleSet) decide(channel enrichedChannel, now time.Time) ruleDecision {
+	for _, r := range rules.Rules {
+		reason, ok := r.matches(channel, now)
+		if !ok {
+			continue
+		}
+		return ruleDecision{
+			channel: channel.subscription,
+			keep:    r.Action != "drop",
+			route:   r.Route,
+			reason:  fmt.Sprintf("%s: %s", r.Name, reason),
+		}
+	}
+	return ruleDecision{channel: channel.subscription, keep: rules.Default == "keep", reason: "no rule matched"}
+}
+
+// matches reports whether a channel meets all of the rule's conditions and
+// describes them.
+func (r rule) matches(channel enrichedChannel, now time.Time) (string, bool) {
+	var reasons []string
+
+	if r.title != nil {
+		if !r.title.MatchString(channel.subscription.Snippet.Title) {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("title matches %q", r.Title))
+	}
+
+	if r.Topic != "" {
+		found := false
+		for _, topic := range channel.topics {
+			found = found || strings.EqualFold(topic, r.Topic)
+		}
+		if !found {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("topic is %s", r.Topic))
+	}
+
+	if r.MinSubscribers > 0 || r.MaxSubscribers > 0 {
+		if !channel.subscribersKnown {
+			return "", false
+		}
+		if r.MinSubscribers > 0 && channel.subscribers < r.MinSubscribers ||
+			r.MaxSubscribers > 0 && channel.subscribers > r.MaxSubscribers {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("%s subscribers", formatCount(channel.subscribers)))
+	}
+
+	if r.inactiveFor > 0 || r.activeWithin > 0 {
+		if !channel.lastUploadKnown {
+			return "", false
+		}
+		if channel.lastUpload.IsZero() {
+			if r.activeWithin > 0 {
+				return "", false
+			}
+			reasons = append(reasons, "no uploads")
+		} else {
+			sinceUpload := now.Sub(channel.lastUpload)
+			if r.inactiveFor > 0 && sinceUpload < r.inactiveFor || r.activeWithin > 0 && sinceUpload > r.activeWithin {
+				return "", false
+			}
+			reasons = append(reasons, fmt.Sprintf("last upload %s", formatDate(channel.lastUpload)))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return "matches every channel", true
+	}
+	return strings.Join(reasons, ", "), true
+}
+
+// applyRules enriches the channels with the details the rules need and
+// decides on each of them.
+func applyRules(ctx context.Context, service *youtube.Service, rules *ruleSet, subscriptions []*youtube.Subscription) ([]ruleDecision, error) {
+	channels, err := enrichChannels(ctx, service, subscriptions, rules.matchesActivity())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	decisions := make([]ruleDecision, 0, len(channels))
+	for _, channel := range channels {
+		decisions = append(decisions, rules.decide(channel, now))
+	}
+	return decisions, nil
+}
+
+// enrichChannels looks up the topics and subscriber counts of the channels
+// and, if withActivity is set, when each last uploaded. Details the
+// credentials aren't allowed to look up are left unknown with a warning, so
+// rules needing them don't match, rather than failing.
+func enrichChannels(ctx context.Context, service *youtube.Service, subscriptions []*youtube.Subscription, withActivity bool) ([]enrichedChannel, error) {
+	channelIDs := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		channelIDs = append(channelIDs, subscription.Snippet.ResourceId.ChannelId)
+	}
+
+	parts := []string{"topicDetails", "statistics", "contentDetails"}
+	details := make(map[string]*youtube.Channel)
+	for start := 0; start < len(channelIDs); start += maxChannelsPerRequest {
+		end := start + maxChannelsPerRequest
+		if end > len(channelIDs) {
+			end = len(channelIDs)
+		}
+
+		list := func(parts []string) (*youtube.ChannelListResponse, error) {
+			return service.Channels.List(parts).Id(channelIDs[start:end]...).Context(ctx).Do()
+		}
+		response, err := list(parts)
+		if isPermissionDenied(err) {
+			if parts, err = allowedParts(parts, list, err); err != nil {
+				return nil, err
+			}
+			response, err = list(parts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range response.Items {
+			details[channel.Id] = channel
+		}
+	}
+
+	channels := make([]enrichedChannel, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		enriched := enrichedChannel{subscription: subscription}
+
+		channel := details[subscription.Snippet.ResourceId.ChannelId]
+		if channel == nil {
+			channels = append(channels, enriched)
+			continue
+		}
+		enriched.exists = true
+
+		if channel.TopicDetails != nil {
+			for _, topicURL := range channel.TopicDetails.TopicCategories {
+				enriched.topics = append(enriched.topics, topicName(topicURL))
+			}
+		}
+		if channel.Statistics != nil && !channel.Statistics.HiddenSubscriberCount {
+			enriched.subscribers = channel.Statistics.SubscriberCount
+			enriched.subscribersKnown = true
+		}
+		if withActivity && channel.ContentDetails != nil && channel.ContentDetails.RelatedPlaylists != nil {
+			lastUpload, err := lastUploadTime(ctx, service, channel.ContentDetails.RelatedPlaylists.Uploads)
+			if isPermissionDenied(err) {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to look up the channels' uploads, leaving them unknown so rules on activity don't match: %v", err)))
+				withActivity = false
+			} else if err != nil {
+				return nil, err
+			} else {
+				enriched.lastUpload = lastUpload
+				enriched.lastUploadKnown = true
+			}
+		}
+
+		channels = append(channels, enriched)
+	}
+	return channels, nil
+}
+
+// allowedParts tries each of the parts on its own when asking for all of
+// them was denied with denial, and warns about and leaves out the denied
+// ones. If every part is denied, only the channels' IDs are asked for, which
+// still tells which channels exist.
+func allowedParts(parts []string, list func(parts []string) (*youtube.ChannelListResponse, error), denial error) ([]string, error) {
+	var allowed, denied []string
+	for _, part := range parts {
+		_, err := list([]string{part})
+		if isPermissionDenied(err) {
+			denied = append(denied, part)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		allowed = append(allowed, part)
+	}
+	if len(denied) == 0 {
+		return nil, denial
+	}
+	fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to look up the %s of channels, leaving them unknown so rules needing them don't match: %v", strings.Join(denied, ", "), denial)))
+	if len(allowed) == 0 {
+		return []string{"id"}, nil
+	}
+	return allowed, nil
+}
+
+// lastUploadTime returns when the newest video in a channel's uploads
+// playlist was published, zero if there are none.
+func lastUploadTime(ctx context.Context, service *youtube.Service, uploadsPlaylistID string) (time.Time, error) {
+	if uploadsPlaylistID == "" {
+		return time.Time{}, nil
+	}
+
+	response, err := service.PlaylistItems.List([]string{"contentDetails"}).PlaylistId(uploadsPlaylistID).MaxResults(1).Context(ctx).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if len(response.Items) == 0 || response.Items[0].ContentDetails == nil {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, response.Items[0].ContentDetails.VideoPublishedAt)
+}
+
+// keptChannels returns the channels the rules kept, printing every decision.
+func keptChannels(decisions []ruleDecision) []*youtube.Subscription {
+	var kept []*youtube.Subscription
+	for _, decision := range decisions {
+		fmt.Printf("  %v\n", decision)
+		if decision.keep {
+			kept = append(kept, decision.channel)
+		}
+	}
+	fmt.Printf("The rules kept %s of %s channels\n", formatCount(len(kept)), formatCount(len(decisions)))
+	return kept
+}
+
+// rulesCommand explains what a rules file decides for each of the source
+// account's subscriptions, without changing anything.
+func rulesCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("rules", flag.ExitOnError)
+	addAPIFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s rules [FLAGS] FILE\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		args = flags.Args()
+		if len(args) != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		rules, err := readRules(args[0])
+		if err != nil {
+			log.Fatalf("Unable to read rules %s: %v", args[0], err)
+		}
+
+		ctx := context.Background()
+		var service *youtube.Service
+		sourceService := func() *youtube.Service {
+			if service == nil {
+				service = getService(ctx, "source", youtube.YoutubeReadonlyScope)
+			}
+			return service
+		}
+
+		subscriptions, err := sourceSubscriptions(ctx, sourceService, false)
+		if err != nil && len(subscriptions) == 0 {
+			log.Fatalf("Unable to list source channels: %v", err)
+		}
+
+		decisions, err := applyRules(ctx, sourceService(), rules, subscriptions)
+		if err != nil {
+			log.Fatalf("Unable to look up channel details: %v", err)
+		}
+		keptChannels(decisions)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression: the minutes, hours, days of the
+// month, months and weekdays it matches.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays []bool
+	// anyDay and anyWeekday are whether the day of the month and the
+	// weekday are *, as a time matches either if both are restricted
+	anyDay, anyWeekday bool
+}
+
+// cronMacros are the shorthands for common schedules.
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// parseCron parses a cron expression of five fields, minute, hour, day of
+// the month, month and weekday, each * or a list of numbers and ranges
+// optionally stepped, e.g. "30 2 * * 1-5" or "*/15 * * * *", or one of
+// cronMacros.
+func parseCron(expression string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[expression]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q has %d fields instead of minute, hour, day of month, month and weekday", expression, len(fields))
+	}
+
+	schedule := &cronSchedule{anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	for i, field := range []struct {
+		values   *[]bool
+		name     string
+		min, max int
+	}{
+		{&schedule.minutes, "minute", 0, 59},
+		{&schedule.hours, "hour", 0, 23},
+		{&schedule.days, "day of month", 1, 31},
+		{&schedule.months, "month", 1, 12},
+		// Sunday is both 0 and 7
+		{&schedule.weekdays, "weekday", 0, 7},
+	} {
+		if *field.values, err = parseCronField(fields[i], field.min, field.max); err != nil {
+			return nil, fmt.Errorf("%s %q: %v", field.name, fields[i], err)
+		}
+	}
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+	return schedule, nil
+}
+
+// parseCronField returns which of the values up to max a field matches.
+func parseCronField(field string, min, max int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			part = rangePart
+		}
+
+		first, last := min, max
+		if part != "*" {
+			from, to, isRange := strings.Cut(part, "-")
+			var err error
+			if first, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", from)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q", to)
+				}
+			} else if step > 1 {
+				// 5/10 means every 10 starting at 5
+				last = max
+			}
+			if first < min || last > max {
+				return nil, fmt.Errorf("%s is outside %d-%d", part, min, max)
+			}
+			if first > last {
+				return nil, fmt.Errorf("%s ends before it starts", part)
+			}
+		}
+		for value := first; value <= last; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// next returns the first minute after after the schedule matches, in
+// after's time zone, or the zero time if it matches none in the next few
+// years, such as on the 31st of February.
+func (schedule *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !schedule.months[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !schedule.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !schedule.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !schedule.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches, which it does if either
+// the day of the month or the weekday matches when both are restricted, as
+// in other crons.
+func (schedule *cronSchedule) matchesDay(t time.Time) bool {
+	day, weekday := schedule.days[t.Day()], schedule.weekdays[t.Weekday()]
+	switch {
+	case schedule.anyDay && schedule.anyWeekday:
+		return true
+	case schedule.anyDay:
+		return weekday
+	case schedule.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// addScheduleFlag adds the -schedule flag to the flags of a command that
+// can run on a schedule.
+func addScheduleFlag(flags *flag.FlagSet) *string {
+	return flags.String("schedule", "", "keep running and start the command at the times of this cron expression in the local time zone, e.g. \"0 3 * * *\" for every night at 3")
+}
+
+// runOnSchedule keeps running command with args at the times of the cron
+// expression, each time as a new process of this program so a run that
+// fails doesn't end the schedule, and prints how each run went. The state
+// is kept between runs in the state file like between any runs. It returns
+// once asked to stop, after the run in progress if any.
+func runOnSchedule(command, expression string, args []string) error {
+	schedule, err := parseCron(expression)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// The runs themselves aren't scheduled, whether -schedule is on the
+	// command line, in the config file or in the environment
+	runArgs := append([]string{command, "-schedule="}, withoutFlag(args, "schedule")...)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for {
+		now := time.Now()
+		next := schedule.next(now)
+		if next.IsZero() {
+			return errors.New("the schedule never runs")
+		}
+		fmt.Printf("Next %s run at %s (in %v), press Ctrl-C to stop\n", command, formatDateTime(next), next.Sub(now).Round(time.Second))
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C:
+		case <-signals:
+			timer.Stop()
+			fmt.Println("\nStopped the schedule")
+			return nil
+		}
+
+		started := time.Now()
+		fmt.Printf("Starting the scheduled %s run\n", command)
+		run := exec.Command(executable, runArgs...)
+		run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+		err := run.Run()
+
+		took := time.Since(started).Round(time.Second)
+		if err != nil {
+			fmt.Println(colorize(colorRed, fmt.Sprintf("The %s run of %s failed after %v: %v", command, formatDateTime(started), took, err)))
+		} else {
+			fmt.Println(colorize(colorGreen, fmt.Sprintf("The %s run of %s finished after %v", command, formatDateTime(started), took)))
+		}
+
+		// Ctrl-C reaches the run too, which stops and saves, so the
+		// schedule ends with it
+		select {
+		case <-signals:
+			fmt.Println("Stopped the schedule")
+			return nil
+		default:
+		}
+	}
+}
+
+// withoutFlag returns args without the flag name and its value, in any of
+// the forms the flag package accepts.
+func withoutFlag(args []string, name string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(kept, args[i:]...)
+		}
+		bare := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		switch {
+		case bare == name && bare != arg:
+			// The value is the next argument
+			i++
+		case strings.HasPrefix(bare, name+"=") && bare != arg:
+		default:
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
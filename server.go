@@ -0,0 +1,659 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// serverUser is a user of the server, who signs in with the link they were
+// given when they were added.
+type serverUser struct {
+	// KeyHash is the SHA-256 of the key in the user's sign-in link
+	KeyHash string    `json:"keyHash"`
+	Added   time.Time `json:"added"`
+}
+
+// serverUserPattern is what the names of the server's users look like, as
+// they name the users' directories.
+var serverUserPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// serverSessionCookie holds the signed in user's name and sign-in key.
+const serverSessionCookie = "session"
+
+// serverAuthorizationTimeout is how long a user has to authorize an account
+// once they asked to connect it.
+const serverAuthorizationTimeout = 10 * time.Minute
+
+// maxJobOutput is how much of a transfer's output the server keeps.
+const maxJobOutput = 64 * 1024
+
+// transferServer serves its users a page to connect their source and target
+// accounts and run their transfer. Every user has a directory of their own
+// with their credentials, state file and audit log, and their transfers run
+// as a separate process of the tool, so users are kept apart as if each ran
+// the tool themselves.
+type transferServer struct {
+	dataDir    string
+	url        string
+	dailyQuota int
+	// configs are the OAuth configs of the source and target accounts,
+	// redirecting back to the server
+	configs map[string]*oauth2.Config
+
+	mu sync.Mutex
+	// pending are the authorizations under way, by their OAuth state
+	pending map[string]serverAuthorization
+	// jobs are the last transfer of each user
+	jobs map[string]*serverJob
+}
+
+// serverAuthorization is a user's authorization of one of their accounts.
+type serverAuthorization struct {
+	user, account, verifier string
+	started                 time.Time
+}
+
+// serverJob is a transfer run for a user.
+type serverJob struct {
+	cmd      *exec.Cmd
+	started  time.Time
+	finished time.Time
+	err      error
+	output   *jobOutput
+}
+
+// jobOutput keeps the last maxJobOutput bytes of a transfer's output.
+type jobOutput struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (output *jobOutput) Write(p []byte) (int, error) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	output.data = append(output.data, p...)
+	if len(output.data) > maxJobOutput {
+		output.data = output.data[len(output.data)-maxJobOutput:]
+	}
+	return len(p), nil
+}
+
+func (output *jobOutput) String() string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	return string(output.data)
+}
+
+// serverPageData is what a user's page shows.
+type serverPageData struct {
+	User     string
+	Accounts []serverAccountData
+	Job      *serverJobData
+	// Imported and Total count the channels in the user's state file
+	Imported, Total int
+	Runs            []RunRecord
+	StateError      string
+}
+
+// serverAccountData is one of a user's accounts.
+type serverAccountData struct {
+	Name      string
+	Connected bool
+	Channel   string
+}
+
+// serverJobData is a user's last transfer.
+type serverJobData struct {
+	Running           bool
+	Started, Finished time.Time
+	Error             string
+	Output            string
+}
+
+var serverTemplate = template.Must(template.New("server").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">{{if and .Job .Job.Running}}
+<meta http-equiv="refresh" content="10">{{end}}
+<title>YouTube subscriptions transfer</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: 0.2em 0.5em; text-align: left; }
+pre { background: #eee; padding: 0.5em; overflow: auto; max-height: 30em; }
+form { display: inline; }
+</style>
+</head>
+<body>
+{{if not .User}}
+<h1>YouTube subscriptions transfer</h1>
+<p>Sign in with the link you were given.</p>
+{{else}}
+<h1>{{.User}}: {{.Imported}} of {{.Total}} channels imported</h1>
+<h2>Accounts</h2>
+<table>{{range .Accounts}}
+<tr><td>{{.Name}}</td><td>{{if .Connected}}connected{{if .Channel}} as channel {{.Channel}}{{end}}{{else}}not connected{{end}}</td>
+<td><form method="post" action="connect"><input type="hidden" name="account" value="{{.Name}}"><button>{{if .Connected}}Connect again{{else}}Connect{{end}}</button></form></td></tr>{{end}}
+</table>
+<h2>Transfer</h2>
+{{if .Job}}{{if .Job.Running}}
+<p>Running since {{.Job.Started.Format "2006-01-02 15:04"}}. <form method="post" action="stop"><button>Stop</button></form></p>
+{{else}}
+<p>Last run {{.Job.Started.Format "2006-01-02 15:04"}} to {{.Job.Finished.Format "15:04"}}{{if .Job.Error}}, failed: {{.Job.Error}}{{end}}. <form method="post" action="transfer"><button>Transfer again</button></form></p>
+{{end}}
+<pre>{{.Job.Output}}</pre>
+{{else}}
+<p><form method="post" action="transfer"><button>Start the transfer</button></form></p>
+{{end}}{{if .StateError}}
+<p>Unable to read the state file: {{.StateError}}</p>{{end}}
+<h2>Runs</h2>
+<table>
+<tr><th>Started</th><th>Imported</th><th>Failed</th><th>Quota</th></tr>{{range .Runs}}
+<tr><td>{{.Started.Format "2006-01-02 15:04"}}</td><td>{{.Imported}}</td><td>{{.Failed}}</td><td>{{.QuotaUsed}}{{if .QuotaExceeded}} (exceeded){{end}}</td></tr>{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// addServerFlags adds the flags shared by serve and its subcommands: the
+// directory the users' files are kept in and the address users reach the
+// server at.
+func addServerFlags(flags *flag.FlagSet) (dataDir, url *string) {
+	dataDir = flags.String("data-dir", "server", "directory the users, and each user's credentials and state file, are kept in")
+	url = flags.String("url", "http://localhost:8080", "address users reach the server at, which the client secret must allow redirecting to at /oauth2callback")
+	return dataDir, url
+}
+
+// serveCommands are the subcommands of serve: add-user adds a user, or
+// gives them a new sign-in link, and remove-user removes one.
+var serveCommands = map[string]command{
+	"add-user":    {flags: serveAddUserCommand},
+	"remove-user": {flags: serveRemoveUserCommand},
+}
+
+// serveCommand serves several users, each transferring between their own
+// accounts.
+func serveCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "localhost:8080", "address to serve on")
+	dataDir, url := addServerFlags(flags)
+	dailyQuota := flags.Int("daily-quota", defaultDailyQuota, "daily quota units of the API project, shared by the users' transfers")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s serve [add-user|remove-user NAME] [FLAGS]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	addAPIFlags(flags)
+	return flags, func(args []string) {
+		server := &transferServer{
+			dataDir:    *dataDir,
+			url:        strings.TrimSuffix(*url, "/"),
+			dailyQuota: *dailyQuota,
+			configs:    make(map[string]*oauth2.Config),
+			pending:    make(map[string]serverAuthorization),
+			jobs:       make(map[string]*serverJob),
+		}
+		for account, scope := range accountScopes {
+			config, err := google.ConfigFromJSON(readClientSecret(account), scope)
+			if err != nil {
+				log.Fatalf("Unable to parse client secret file to config: %v", err)
+			}
+			config.RedirectURL = server.url + "/oauth2callback"
+			server.configs[account] = config
+		}
+		if err := os.MkdirAll(server.dataDir, 0700); err != nil {
+			log.Fatalf("Unable to create %s: %v", server.dataDir, err)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", server.handleIndex)
+		mux.HandleFunc("/sign-in", server.handleSignIn)
+		mux.HandleFunc("/connect", server.handleConnect)
+		mux.HandleFunc("/oauth2callback", server.handleCallback)
+		mux.HandleFunc("/transfer", server.handleTransfer)
+		mux.HandleFunc("/stop", server.handleStop)
+
+		fmt.Printf("Serving on http://%s for the users in %s\n", *listen, filepath.Join(server.dataDir, "users.json"))
+		log.Fatal(http.ListenAndServe(*listen, mux))
+	}
+}
+
+// serveAddUserCommand adds a user to the server, printing the link they
+// sign in with. Adding a user again gives them a new link, and the old one
+// stops working.
+func serveAddUserCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("serve add-user", flag.ExitOnError)
+	dataDir, url := addServerFlags(flags)
+	return flags, func(args []string) {
+		if flags.NArg() != 1 || !serverUserPattern.MatchString(flags.Arg(0)) {
+			log.Fatalf("Expected a user name of letters, digits, dots, dashes and underscores")
+		}
+		name := flags.Arg(0)
+
+		users, err := readServerUsers(*dataDir)
+		if err != nil {
+			log.Fatalf("Unable to read the users: %v", err)
+		}
+		key, err := randomState()
+		if err != nil {
+			log.Fatalf("Unable to create a sign-in key: %v", err)
+		}
+		users[name] = serverUser{KeyHash: hashSignInKey(key), Added: time.Now()}
+		if err := writeServerUsers(*dataDir, users); err != nil {
+			log.Fatalf("Unable to save the users: %v", err)
+		}
+
+		fmt.Printf("%s signs in at:\n%s/sign-in?user=%s&key=%s\n", name, strings.TrimSuffix(*url, "/"), name, key)
+	}
+}
+
+// serveRemoveUserCommand removes a user from the server. Their files are
+// left in their directory.
+func serveRemoveUserCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("serve remove-user", flag.ExitOnError)
+	dataDir, _ := addServerFlags(flags)
+	return flags, func(args []string) {
+		if flags.NArg() != 1 {
+			log.Fatalf("Expected the name of the user to remove")
+		}
+		name := flags.Arg(0)
+
+		users, err := readServerUsers(*dataDir)
+		if err != nil {
+			log.Fatalf("Unable to read the users: %v", err)
+		}
+		if _, ok := users[name]; !ok {
+			log.Fatalf("There is no user %s", name)
+		}
+		delete(users, name)
+		if err := writeServerUsers(*dataDir, users); err != nil {
+			log.Fatalf("Unable to save the users: %v", err)
+		}
+		fmt.Printf("Removed %s, their credentials and state file are left in %s\n", name, serverUserDir(*dataDir, name))
+	}
+}
+
+// readServerUsers returns the server's users by name.
+func readServerUsers(dataDir string) (map[string]serverUser, error) {
+	users := make(map[string]serverUser)
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "users.json"))
+	if os.IsNotExist(err) {
+		return users, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return users, json.Unmarshal(data, &users)
+}
+
+func writeServerUsers(dataDir string, users map[string]serverUser) error {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath.Join(dataDir, "users.json"), 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(users)
+	})
+}
+
+func hashSignInKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// serverUserDir returns the directory a user's files are kept in.
+func serverUserDir(dataDir, name string) string {
+	return filepath.Join(dataDir, "users", name)
+}
+
+// userDir returns the directory a user's files are kept in, and the
+// credentials directory in it.
+func (server *transferServer) userDir(name string) (dir, credentialsDir string) {
+	dir = serverUserDir(server.dataDir, name)
+	return dir, filepath.Join(dir, "credentials")
+}
+
+// signedInUser returns the name of the user the request's session is for,
+// or "" if it isn't for a user.
+func (server *transferServer) signedInUser(r *http.Request) string {
+	cookie, err := r.Cookie(serverSessionCookie)
+	if err != nil {
+		return ""
+	}
+	name, key, _ := strings.Cut(cookie.Value, ":")
+	if server.checkSignInKey(name, key) {
+		return name
+	}
+	return ""
+}
+
+// checkSignInKey reports whether key is the user's sign-in key. The users
+// are read afresh, so added and removed users take effect right away.
+func (server *transferServer) checkSignInKey(name, key string) bool {
+	users, err := readServerUsers(server.dataDir)
+	if err != nil {
+		log.Printf("Unable to read the users: %v", err)
+		return false
+	}
+	user, ok := users[name]
+	return ok && key != "" && subtle.ConstantTimeCompare([]byte(user.KeyHash), []byte(hashSignInKey(key))) == 1
+}
+
+func (server *transferServer) handleSignIn(w http.ResponseWriter, r *http.Request) {
+	name, key := r.URL.Query().Get("user"), r.URL.Query().Get("key")
+	if !server.checkSignInKey(name, key) {
+		http.Error(w, "This sign-in link isn't valid, ask for a new one", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     serverSessionCookie,
+		Value:    name + ":" + key,
+		Path:     "/",
+		MaxAge:   int((90 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(server.url, "https:"),
+		// Other sites' forms are sent without the cookie, so they can't
+		// start transfers
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, server.url+"/", http.StatusSeeOther)
+}
+
+func (server *transferServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	data := serverPageData{User: server.signedInUser(r)}
+	if data.User == "" {
+		if err := serverTemplate.Execute(w, data); err != nil {
+			log.Printf("Unable to render page: %v", err)
+		}
+		return
+	}
+
+	dir, credentialsDir := server.userDir(data.User)
+	channels := readAccountChannelsFile(filepath.Join(credentialsDir, "channels.json"))
+	for _, account := range stateFileAccounts {
+		_, err := os.Stat(tokenFileIn(credentialsDir, account))
+		data.Accounts = append(data.Accounts, serverAccountData{Name: account, Connected: err == nil, Channel: channels[account]})
+	}
+
+	server.mu.Lock()
+	if job := server.jobs[data.User]; job != nil {
+		data.Job = &serverJobData{Running: job.finished.IsZero(), Started: job.started, Finished: job.finished, Output: job.output.String()}
+		if job.err != nil {
+			data.Job.Error = job.err.Error()
+		}
+	}
+	server.mu.Unlock()
+
+	state, err := readStateFromFile(filepath.Join(dir, defaultStateFile))
+	if err == nil {
+		data.Total, data.Runs = len(state.Channels), state.Runs
+		for _, channelStatus := range state.Channels {
+			if channelStatus.Imported {
+				data.Imported++
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		data.StateError = err.Error()
+	}
+
+	if err := serverTemplate.Execute(w, data); err != nil {
+		log.Printf("Unable to render page: %v", err)
+	}
+}
+
+// handleConnect sends the user to Google to authorize one of their
+// accounts, which redirects back to handleCallback.
+func (server *transferServer) handleConnect(w http.ResponseWriter, r *http.Request) {
+	user := server.signedInUser(r)
+	if r.Method != http.MethodPost || user == "" {
+		http.Error(w, "Sign in first", http.StatusForbidden)
+		return
+	}
+	account := r.FormValue("account")
+	config, ok := server.configs[account]
+	if !ok {
+		http.Error(w, "Unknown account", http.StatusBadRequest)
+		return
+	}
+
+	state, err := randomState()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to start authorization: %v", err), http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	server.mu.Lock()
+	for pendingState, authorization := range server.pending {
+		if time.Since(authorization.started) > serverAuthorizationTimeout {
+			delete(server.pending, pendingState)
+		}
+	}
+	server.pending[state] = serverAuthorization{user: user, account: account, verifier: verifier, started: time.Now()}
+	server.mu.Unlock()
+
+	// Asking which account to use lets a Google account with several
+	// channels pick one
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("prompt", "select_account consent"))
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+// handleCallback caches the credentials of the account the user authorized
+// in their credentials directory, and remembers its channel.
+func (server *transferServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	server.mu.Lock()
+	authorization, ok := server.pending[query.Get("state")]
+	delete(server.pending, query.Get("state"))
+	server.mu.Unlock()
+
+	user := server.signedInUser(r)
+	if !ok || user == "" || authorization.user != user || time.Since(authorization.started) > serverAuthorizationTimeout {
+		http.Error(w, "Unexpected authorization response, connect the account again", http.StatusBadRequest)
+		return
+	}
+	if reason := query.Get("error"); reason != "" {
+		http.Error(w, fmt.Sprintf("Authorizing the %s account failed: %s", authorization.account, reason), http.StatusForbidden)
+		return
+	}
+
+	config := server.configs[authorization.account]
+	token, err := config.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(authorization.verifier))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to retrieve token: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	_, credentialsDir := server.userDir(user)
+	err = os.MkdirAll(credentialsDir, 0700)
+	if err == nil {
+		err = writeFileAtomically(tokenFileIn(credentialsDir, authorization.account), 0600, func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(token)
+		})
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to save the credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("%s connected their %s account", user, authorization.account)
+
+	// The channel shows which one was picked, and names the state file
+	// like it does for accounts authorized with auth
+	ctx := context.Background()
+	service, err := youtube.NewService(ctx, option.WithHTTPClient(config.Client(ctx, token)))
+	var channel *youtube.Channel
+	if err == nil {
+		channel, err = authorizedChannel(ctx, service)
+	}
+	if err != nil {
+		log.Printf("Unable to look up %s's %s account: %v", user, authorization.account, err)
+	} else if channel != nil {
+		if err := rememberAccountChannelIn(filepath.Join(credentialsDir, "channels.json"), authorization.account, channel.Id); err != nil {
+			log.Printf("Unable to remember %s's %s channel: %v", user, authorization.account, err)
+		}
+	}
+
+	http.Redirect(w, r, server.url+"/", http.StatusSeeOther)
+}
+
+// handleTransfer starts a transfer for the user, unless one is running. It
+// runs as a separate process of the tool with the user's credentials and
+// state file, and a quota ledger shared by all users.
+func (server *transferServer) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	user := server.signedInUser(r)
+	if r.Method != http.MethodPost || user == "" {
+		http.Error(w, "Sign in first", http.StatusForbidden)
+		return
+	}
+	_, credentialsDir := server.userDir(user)
+	for _, account := range stateFileAccounts {
+		if _, err := os.Stat(tokenFileIn(credentialsDir, account)); err != nil {
+			http.Error(w, "Connect both accounts first", http.StatusBadRequest)
+			return
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if job := server.jobs[user]; job != nil && job.finished.IsZero() {
+		http.Redirect(w, r, server.url+"/", http.StatusSeeOther)
+		return
+	}
+
+	cmd, err := server.transferCommand(user)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to start the transfer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	job := &serverJob{cmd: cmd, started: time.Now(), output: &jobOutput{}}
+	cmd.Stdout, cmd.Stderr = job.output, job.output
+	if err := cmd.Start(); err != nil {
+		http.Error(w, fmt.Sprintf("Unable to start the transfer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	server.jobs[user] = job
+	log.Printf("Started a transfer for %s", user)
+
+	go func() {
+		err := cmd.Wait()
+		server.mu.Lock()
+		job.finished, job.err = time.Now(), err
+		server.mu.Unlock()
+		log.Printf("The transfer for %s finished: %v", user, err)
+	}()
+
+	http.Redirect(w, r, server.url+"/", http.StatusSeeOther)
+}
+
+// transferCommand returns the command running a transfer for the user. The
+// paths it is given are absolute, as it runs in the user's directory.
+func (server *transferServer) transferCommand(user string) (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dataDir, err := filepath.Abs(server.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	dir := serverUserDir(dataDir, user)
+	credentialsDir := filepath.Join(dir, "credentials")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	// The credentials are kept in the user's directory, never the keyring
+	// shared by all users
+	args := []string{"transfer", "-plain",
+		"-credentials-dir", credentialsDir, "-keyring=false",
+		"-state-file", filepath.Join(dir, defaultStateFile),
+		"-audit-log", filepath.Join(dir, "audit.jsonl"),
+		"-quota-user", user,
+		"-quota-ledger", filepath.Join(dataDir, "quota-ledger.json"),
+		"-daily-quota", strconv.Itoa(server.dailyQuota),
+	}
+	// The client secret can also be given in the environment, which the
+	// transfer inherits, unless -client-secret was passed over it. One read
+	// from stdin is handed on the same way for -client-secret, and on the
+	// transfer's stdin for the accounts' own secrets.
+	var env []string
+	var stdin io.Reader
+	for flagName, file := range map[string]string{
+		"client-secret":        clientSecretFile,
+		"source-client-secret": sourceClientSecretFile,
+		"target-client-secret": targetClientSecretFile,
+	} {
+		switch {
+		case file == "":
+			continue
+		case flagName == "client-secret" && os.Getenv(clientSecretEnv) != "" && !passedFlags[flagName]:
+			continue
+		case file == "-" && flagName == "client-secret":
+			env = append(os.Environ(), clientSecretEnv+"="+string(stdinClientSecret))
+			continue
+		case file == "-":
+			stdin = bytes.NewReader(stdinClientSecret)
+		default:
+			if file, err = filepath.Abs(file); err != nil {
+				return nil, err
+			}
+		}
+		args = append(args, "-"+flagName, file)
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = stdin
+	return cmd, nil
+}
+
+// handleStop asks the user's running transfer to stop after the current
+// channel, like Ctrl-C does.
+func (server *transferServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	user := server.signedInUser(r)
+	if r.Method != http.MethodPost || user == "" {
+		http.Error(w, "Sign in first", http.StatusForbidden)
+		return
+	}
+
+	server.mu.Lock()
+	if job := server.jobs[user]; job != nil && job.finished.IsZero() {
+		// Windows can't signal a process, only kill it
+		if err := job.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			job.cmd.Process.Kill()
+		}
+	}
+	server.mu.Unlock()
+
+	http.Redirect(w, r, server.url+"/", http.StatusSeeOther)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/net/context"
+)
+
+// handleShutdown returns a context that is cancelled on Ctrl-C or when asked
+// to terminate, including the console window being closed on Windows, so
+// the import can stop after the current channel and save its progress. A
+// second signal quits right away, calling cleanup first.
+func handleShutdown(cleanup func()) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		fmt.Println("\nStopping after the current channel, press Ctrl-C again to quit right away")
+		cancel()
+
+		<-signals
+		cleanup()
+		os.Exit(130)
+	}()
+
+	return ctx
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// slackNotifier posts a message to a Slack channel through an incoming
+// webhook whenever the state of the transfer changes.
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newSlackNotifier(url string, options notifierOptions) (notifier, error) {
+	if url == "" {
+		return nil, errors.New("expected an incoming webhook URL, e.g. slack=https://hooks.slack.com/services/...")
+	}
+	return onStateChange(&slackNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}), nil
+}
+
+func (slack *slackNotifier) notify(update progressUpdate) error {
+	payload, err := json.Marshal(map[string]string{"text": update.summary()})
+	if err != nil {
+		return err
+	}
+
+	response, err := slack.client.Post(slack.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with %s", slack.url, response.Status)
+	}
+	return nil
+}
+
+func (slack *slackNotifier) close() {}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// sourceSnapshotFile returns the file caching the subscriptions of the
+// source channel, so commands run one after another don't each spend quota
+// listing them. Each source channel has its own, so transfers from
+// different source accounts don't use each other's.
+func sourceSnapshotFile(sourceChannel string) string {
+	return "sourceSubscriptions-" + sourceChannel + ".gob"
+}
+
+// sourceSnapshotMaxAge is how long a snapshot is used before the
+// subscriptions are listed again.
+const sourceSnapshotMaxAge = 24 * time.Hour
+
+// sourceSnapshot is a complete listing of the source account's
+// subscriptions and when it was made.
+type sourceSnapshot struct {
+	Fetched       time.Time
+	Subscriptions []*youtube.Subscription
+	// SourceChannel is the channel whose subscriptions these are, the
+	// source account's or -source-channel-id
+	SourceChannel string
+}
+
+// sourceSubscriptions returns the source account's subscriptions from the
+// snapshot if it is recent enough, otherwise lists them with the service
+// returned by sourceService, which is only called then, and snapshots
+// complete listings. refresh lists them even if the snapshot is recent. The
+// snapshot is only used once the source channel is known, which it is after
+// the first listing.
+func sourceSubscriptions(ctx context.Context, sourceService func() *youtube.Service, refresh bool) ([]*youtube.Subscription, error) {
+	sourceChannel := accountChannels()["source"]
+	if !refresh && sourceChannel != "" {
+		snapshot, err := readSourceSnapshot(sourceChannel)
+		if err == nil && snapshot.SourceChannel == sourceChannel && time.Since(snapshot.Fetched) < sourceSnapshotMaxAge {
+			fmt.Printf("Using the %v source subscriptions listed %v ago, pass -refresh to list them again\n",
+				len(snapshot.Subscriptions), time.Since(snapshot.Fetched).Round(time.Minute))
+			if encrypted, err := isEncryptedFile(sourceSnapshotFile(sourceChannel)); err == nil && !encrypted && stateEncrypted() {
+				writeSourceSnapshot(*snapshot)
+			}
+			return snapshot.Subscriptions, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to read %s, listing the subscriptions again: %v", sourceSnapshotFile(sourceChannel), err)))
+		}
+	}
+
+	service := sourceService()
+	if sourceChannel == "" {
+		channel, err := authorizedChannel(ctx, service)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up the source account's channel: %w", err)
+		}
+		if channel != nil {
+			sourceChannel = channel.Id
+			if err := rememberAccountChannel("source", sourceChannel); err != nil {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to remember the source account's channel: %v", err)))
+			}
+		}
+	}
+
+	fmt.Println("Fetching subscriptions")
+	subscriptions, err := subscriptionsWithFallback(ctx, service, sourceChannelID, []string{"snippet", "contentDetails"})
+	if err != nil || sourceChannel == "" {
+		return subscriptions, err
+	}
+
+	writeSourceSnapshot(sourceSnapshot{Fetched: time.Now(), Subscriptions: subscriptions, SourceChannel: sourceChannel})
+	return subscriptions, nil
+}
+
+// writeSourceSnapshot saves the snapshot, encrypted like the state file if
+// it is, warning if it can't.
+func writeSourceSnapshot(snapshot sourceSnapshot) {
+	file := sourceSnapshotFile(snapshot.SourceChannel)
+	var data bytes.Buffer
+	err := gob.NewEncoder(&data).Encode(snapshot)
+	if err == nil && stateEncrypted() {
+		var encrypted []byte
+		if encrypted, err = encryptStateData(data.Bytes()); err == nil {
+			data = *bytes.NewBuffer(encrypted)
+		}
+	}
+	if err == nil {
+		err = writeFileAtomically(file, 0600, func(w io.Writer) error {
+			_, err := data.WriteTo(w)
+			return err
+		})
+	}
+	if err != nil {
+		fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to save %s: %v", file, err)))
+	}
+}
+
+func readSourceSnapshot(sourceChannel string) (*sourceSnapshot, error) {
+	data, err := os.ReadFile(sourceSnapshotFile(sourceChannel))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(encryptedStateMagic)) {
+		if data, err = decryptStateData(data); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshot := &sourceSnapshot{}
+	return snapshot, gob.NewDecoder(bytes.NewReader(data)).Decode(snapshot)
+}
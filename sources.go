@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// transferSource is one of the sources of channels merged with -source:
+// the source account, another account, a channel's public subscriptions or
+// a file.
+type transferSource struct {
+	// kind is account, channel or the importer reading the file
+	kind string
+	// value is the other account's name, the channel ID or the file
+	value string
+}
+
+func (source transferSource) String() string {
+	if source.value == "" {
+		return source.kind
+	}
+	return source.kind + "=" + source.value
+}
+
+// addSourceFlag adds the -source flag to the flags of a command that can
+// merge several sources.
+func addSourceFlag(flags *flag.FlagSet) *repeatedFlag {
+	sources := &repeatedFlag{}
+	flags.Var(sources, "source", "source to merge into the transfer instead of only the source account, can be repeated: account for the source account, account=NAME for another account authorized as source-NAME, channel=ID for a channel's public subscriptions, or FORMAT=FILE for a file read like import -from FORMAT: "+strings.Join(importerNames(), ", "))
+	return sources
+}
+
+// parseTransferSources parses the -source values, defaulting to only the
+// source account.
+func parseTransferSources(values []string) ([]transferSource, error) {
+	if len(values) == 0 {
+		return []transferSource{{kind: "account"}}, nil
+	}
+
+	var sources []transferSource
+	for _, value := range values {
+		kind, rest, _ := strings.Cut(value, "=")
+		switch _, isImporter := importers[kind]; {
+		case kind == "account" && rest == "manage":
+			return nil, fmt.Errorf("%s: manage is the name of the source account's credentials for managing it, pick another name", value)
+		case kind == "account":
+		case kind == "channel" && !channelIDPattern.MatchString(rest):
+			return nil, fmt.Errorf("%s: %q isn't a channel ID", value, rest)
+		case kind == "channel":
+		case isImporter && rest != "":
+		default:
+			return nil, fmt.Errorf("%s: expected account, account=NAME, channel=ID or FORMAT=FILE with one of: %s", value, strings.Join(importerNames(), ", "))
+		}
+		sources = append(sources, transferSource{kind: kind, value: rest})
+	}
+	return sources, nil
+}
+
+// usesSourceAccount reports whether any of the sources is the source
+// account, which then has to be authorized.
+func usesSourceAccount(sources []transferSource) bool {
+	for _, source := range sources {
+		if source.kind == "account" && source.value == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// read returns the source's channels, and the tags of those the file tags,
+// such as the groups they are in. Public subscriptions are listed with the
+// source account, and the channels in files looked up with the target
+// account like import does, so a file needs no source account. The source
+// account's subscriptions come from the snapshot unless refresh is set.
+// Each service is only created if the source needs it.
+func (source transferSource) read(ctx context.Context, sourceService, targetService func() *youtube.Service, refresh bool) ([]*youtube.Subscription, map[string][]string, error) {
+	switch {
+	case source.kind == "account" && source.value == "":
+		subscriptions, err := sourceSubscriptions(ctx, sourceService, refresh)
+		return subscriptions, nil, err
+	case source.kind == "account":
+		fmt.Printf("Fetching the subscriptions of the %s account\n", source.value)
+		service := getService(ctx, "source-"+source.value, youtube.YoutubeReadonlyScope)
+		subscriptions, err := subscriptionsWithFallback(ctx, service, "", []string{"snippet", "contentDetails"})
+		return subscriptions, nil, err
+	case source.kind == "channel":
+		fmt.Printf("Fetching the public subscriptions of %s\n", source.value)
+		subscriptions, err := subscriptionsWithFallback(ctx, sourceService(), source.value, []string{"snippet", "contentDetails"})
+		return subscriptions, nil, err
+	}
+
+	references, err := importers[source.kind](source.value, importOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("Found %s channels in %s\n", formatCount(len(references)), source.value)
+
+	channels, unresolved, err := resolveChannels(ctx, targetService(), references)
+	for _, reference := range unresolved {
+		fmt.Printf("Unable to find channel %v %s\n", reference, reference.title)
+	}
+	// Only references by ID are known to be the channel resolved
+	tags := make(map[string][]string)
+	for _, reference := range references {
+		if reference.id != "" && len(reference.tags) > 0 {
+			tags[reference.id] = append(tags[reference.id], reference.tags...)
+		}
+	}
+	return channels, tags, err
+}
+
+// errIncompleteSources is returned along with the channels read when some
+// of the sources could only be read partly.
+var errIncompleteSources = errors.New("the sources could only be read partly")
+
+// readTransferSources reads each of the sources in turn and merges their
+// channels, each channel only once as the first source listing it has it,
+// along with the tags the sources give them. A source that can't be read at
+// all is an error. A source read only partly has its channels merged all
+// the same, and the merged channels are returned with an error wrapping
+// errIncompleteSources, so callers can decide whether a partial list will
+// do.
+func readTransferSources(ctx context.Context, sources []transferSource, sourceService, targetService func() *youtube.Service, refresh bool) ([]*youtube.Subscription, map[string][]string, error) {
+	var merged []*youtube.Subscription
+	tags := make(map[string][]string)
+	seen := make(map[string]bool)
+	var incomplete []string
+	for _, source := range sources {
+		channels, sourceTags, err := source.read(ctx, sourceService, targetService, refresh)
+		if err != nil && len(channels) == 0 {
+			return nil, nil, fmt.Errorf("%s: %v", source, err)
+		} else if err != nil {
+			fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to read all of %s, merging the %v channels read: %v", source, len(channels), err)))
+			incomplete = append(incomplete, fmt.Sprintf("%s: %v", source, err))
+		}
+
+		added := 0
+		for _, channel := range channels {
+			channelID := channel.Snippet.ResourceId.ChannelId
+			if seen[channelID] {
+				continue
+			}
+			seen[channelID] = true
+			merged = append(merged, channel)
+			added++
+		}
+		for channelID, channelTags := range sourceTags {
+			tags[channelID] = append(tags[channelID], channelTags...)
+		}
+		if len(sources) > 1 {
+			fmt.Printf("%s has %s channels, %s of them not in the sources before it\n", source, formatCount(len(channels)), formatCount(added))
+		}
+	}
+	if len(sources) > 1 {
+		fmt.Printf("Merged %s channels from %v sources\n", formatCount(len(merged)), len(sources))
+	}
+	if len(incomplete) > 0 {
+		return merged, tags, fmt.Errorf("%w: %s", errIncompleteSources, strings.Join(incomplete, "; "))
+	}
+	return merged, tags, nil
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// sqliteStateSchema keeps the state in a row per channel, with when it was
+// added and last changed, and the runs with the errors of each.
+const sqliteStateSchema = `
+CREATE TABLE IF NOT EXISTS meta (key TEXT PRIMARY KEY, value TEXT);
+CREATE TABLE IF NOT EXISTS channels (
+	channel_id TEXT PRIMARY KEY,
+	position INTEGER NOT NULL,
+	title TEXT,
+	subscription TEXT NOT NULL,
+	imported INTEGER NOT NULL,
+	unavailable INTEGER NOT NULL,
+	added_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	written INTEGER NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	failure_reason TEXT,
+	failure_error TEXT,
+	failed_at TEXT,
+	failure_permanent INTEGER NOT NULL DEFAULT 0,
+	tags TEXT
+);
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	label TEXT,
+	started TEXT,
+	finished TEXT,
+	imported INTEGER,
+	failed INTEGER,
+	quota_used INTEGER,
+	quota_exceeded INTEGER,
+	unique_id TEXT
+);
+CREATE TABLE IF NOT EXISTS run_errors (run_id INTEGER, channel_id TEXT, error TEXT);
+CREATE TABLE IF NOT EXISTS run_unavailable (run_id INTEGER, channel TEXT);
+CREATE TABLE IF NOT EXISTS accounts (account TEXT PRIMARY KEY, channel_id TEXT);
+`
+
+// sqliteStateColumns are the columns added to the tables after they were
+// first created, added to existing state files when they are opened.
+var sqliteStateColumns = []struct{ table, column string }{
+	{"channels", "attempts INTEGER NOT NULL DEFAULT 0"},
+	{"channels", "failure_reason TEXT"},
+	{"channels", "failure_error TEXT"},
+	{"channels", "failed_at TEXT"},
+	{"channels", "failure_permanent INTEGER NOT NULL DEFAULT 0"},
+	{"channels", "tags TEXT"},
+	{"runs", "unique_id TEXT"},
+}
+
+// isSQLiteStateFile reports whether a state file is kept in SQLite, which
+// is chosen by naming it .db or .sqlite.
+func isSQLiteStateFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return true
+	}
+	return false
+}
+
+func openSQLiteState(file string) (*sql.DB, error) {
+	// Create the file first so it is only readable by its owner
+	f, err := os.OpenFile(file, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteStateSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, column := range sqliteStateColumns {
+		if _, err := db.Exec("ALTER TABLE " + column.table + " ADD COLUMN " + column.column); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// openExistingSQLiteState opens a SQLite state file that exists and was
+// written by a version of the tool that can read it.
+func openExistingSQLiteState(file string) (*sql.DB, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, err
+	}
+	db, err := openSQLiteState(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var version string
+	if err := db.QueryRow("SELECT value FROM meta WHERE key = 'version'").Scan(&version); err == nil {
+		if v, _ := strconv.Atoi(version); v > stateVersion {
+			db.Close()
+			return nil, fmt.Errorf("%w: it is version %d, this version only reads up to version %d", errNewerState, v, stateVersion)
+		}
+	} else if err != sql.ErrNoRows {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func readSQLiteState(file string) (*importState, error) {
+	db, err := openExistingSQLiteState(file)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	state := &importState{}
+	var sourcesIncomplete string
+	if err := db.QueryRow("SELECT value FROM meta WHERE key = 'sourcesIncomplete'").Scan(&sourcesIncomplete); err == nil {
+		state.SourcesIncomplete, _ = strconv.ParseBool(sourcesIncomplete)
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT subscription, imported, unavailable, attempts, failure_reason, failure_error, failed_at, failure_permanent, tags
+		FROM channels ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var subscription string
+		var failureReason, failureError, failedAt, tags sql.NullString
+		var failurePermanent bool
+		channelStatus := ChannelImportStatus{Channel: &youtube.Subscription{}}
+		if err := rows.Scan(&subscription, &channelStatus.Imported, &channelStatus.Unavailable, &channelStatus.Attempts,
+			&failureReason, &failureError, &failedAt, &failurePermanent, &tags); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(subscription), channelStatus.Channel); err != nil {
+			return nil, err
+		}
+		if tags.Valid {
+			if err := json.Unmarshal([]byte(tags.String), &channelStatus.Tags); err != nil {
+				return nil, err
+			}
+		}
+		if failureError.Valid {
+			channelStatus.Failure = &channelFailure{Reason: failureReason.String, Error: failureError.String, Permanent: failurePermanent}
+			channelStatus.Failure.Time, _ = time.Parse(time.RFC3339Nano, failedAt.String)
+		}
+		state.Channels = append(state.Channels, channelStatus)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	runs := make(map[int64]int)
+	runRows, err := db.Query("SELECT id, label, started, finished, imported, failed, quota_used, quota_exceeded, unique_id FROM runs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer runRows.Close()
+	for runRows.Next() {
+		var id int64
+		var started, finished string
+		var uniqueID sql.NullString
+		run := RunRecord{}
+		if err := runRows.Scan(&id, &run.Label, &started, &finished, &run.Imported, &run.Failed, &run.QuotaUsed, &run.QuotaExceeded, &uniqueID); err != nil {
+			return nil, err
+		}
+		run.ID = uniqueID.String
+		run.Started, _ = time.Parse(time.RFC3339Nano, started)
+		run.Finished, _ = time.Parse(time.RFC3339Nano, finished)
+		runs[id] = len(state.Runs)
+		state.Runs = append(state.Runs, run)
+	}
+	if err := runRows.Err(); err != nil {
+		return nil, err
+	}
+
+	errorRows, err := db.Query("SELECT run_id, channel_id, error FROM run_errors ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer errorRows.Close()
+	for errorRows.Next() {
+		var id int64
+		var channelID, runError string
+		if err := errorRows.Scan(&id, &channelID, &runError); err != nil {
+			return nil, err
+		}
+		if index, ok := runs[id]; ok {
+			state.Runs[index].Errors = append(state.Runs[index].Errors, channelID+": "+runError)
+		}
+	}
+	if err := errorRows.Err(); err != nil {
+		return nil, err
+	}
+
+	unavailableRows, err := db.Query("SELECT run_id, channel FROM run_unavailable ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer unavailableRows.Close()
+	for unavailableRows.Next() {
+		var id int64
+		var channel string
+		if err := unavailableRows.Scan(&id, &channel); err != nil {
+			return nil, err
+		}
+		if index, ok := runs[id]; ok {
+			state.Runs[index].Unavailable = append(state.Runs[index].Unavailable, channel)
+		}
+	}
+	if err := unavailableRows.Err(); err != nil {
+		return nil, err
+	}
+
+	accountRows, err := db.Query("SELECT account, channel_id FROM accounts")
+	if err != nil {
+		return nil, err
+	}
+	defer accountRows.Close()
+	for accountRows.Next() {
+		var account, channelID string
+		if err := accountRows.Scan(&account, &channelID); err != nil {
+			return nil, err
+		}
+		if state.Accounts == nil {
+			state.Accounts = make(map[string]string)
+		}
+		state.Accounts[account] = channelID
+	}
+	return state, accountRows.Err()
+}
+
+// uniqueChannels returns the channels with each only once, as SQLite state
+// files key them by their ID. A channel listed more than once keeps its
+// first position, and counts as imported if any of its copies was.
+func uniqueChannels(channels []ChannelImportStatus) []ChannelImportStatus {
+	unique := make([]ChannelImportStatus, 0, len(channels))
+	index := make(map[string]int)
+	for _, channelStatus := range channels {
+		channelID := channelStatus.Channel.Snippet.ResourceId.ChannelId
+		first, seen := index[channelID]
+		if !seen {
+			index[channelID] = len(unique)
+			unique = append(unique, channelStatus)
+		} else if channelStatus.Imported && !unique[first].Imported {
+			unique[first].Imported = true
+			unique[first].Unavailable = false
+			unique[first].Failure = nil
+		}
+	}
+	return unique
+}
+
+// writeSQLiteState saves the state in a single transaction. Channels keep
+// when they were added, and when their status last changed.
+func writeSQLiteState(file string, state *importState) error {
+	db, err := openSQLiteState(file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var written int64
+	if err := tx.QueryRow("SELECT COALESCE(MAX(written), 0) + 1 FROM channels").Scan(&written); err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for position, channelStatus := range uniqueChannels(state.Channels) {
+		subscription, err := json.Marshal(channelStatus.Channel)
+		if err != nil {
+			return err
+		}
+		var failureReason, failureError, failedAt, tags sql.NullString
+		var failurePermanent bool
+		if len(channelStatus.Tags) > 0 {
+			encoded, err := json.Marshal(channelStatus.Tags)
+			if err != nil {
+				return err
+			}
+			tags = sql.NullString{String: string(encoded), Valid: true}
+		}
+		if failure := channelStatus.Failure; failure != nil {
+			failureReason = sql.NullString{String: failure.Reason, Valid: true}
+			failureError = sql.NullString{String: failure.Error, Valid: true}
+			failedAt = sql.NullString{String: failure.Time.Format(time.RFC3339Nano), Valid: true}
+			failurePermanent = failure.Permanent
+		}
+		snippet := channelStatus.Channel.Snippet
+		if _, err := tx.Exec(`INSERT INTO channels (channel_id, position, title, subscription, imported, unavailable,
+				added_at, updated_at, written, attempts, failure_reason, failure_error, failed_at, failure_permanent, tags)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (channel_id) DO UPDATE SET
+				position = excluded.position,
+				title = excluded.title,
+				subscription = excluded.subscription,
+				updated_at = CASE WHEN imported != excluded.imported OR unavailable != excluded.unavailable
+					THEN excluded.updated_at ELSE updated_at END,
+				imported = excluded.imported,
+				unavailable = excluded.unavailable,
+				written = excluded.written,
+				attempts = excluded.attempts,
+				failure_reason = excluded.failure_reason,
+				failure_error = excluded.failure_error,
+				failed_at = excluded.failed_at,
+				failure_permanent = excluded.failure_permanent,
+				tags = excluded.tags`,
+			snippet.ResourceId.ChannelId, position, snippet.Title, string(subscription),
+			channelStatus.Imported, channelStatus.Unavailable, now, now, written,
+			channelStatus.Attempts, failureReason, failureError, failedAt, failurePermanent, tags); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM channels WHERE written != ?", written); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"runs", "run_errors", "run_unavailable", "accounts"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+	for index, run := range state.Runs {
+		id := index + 1
+		if _, err := tx.Exec("INSERT INTO runs (id, label, started, finished, imported, failed, quota_used, quota_exceeded, unique_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", id, run.Label,
+			run.Started.Format(time.RFC3339Nano), run.Finished.Format(time.RFC3339Nano),
+			run.Imported, run.Failed, run.QuotaUsed, run.QuotaExceeded, run.ID); err != nil {
+			return err
+		}
+		for _, runError := range run.Errors {
+			channelID, message, _ := strings.Cut(runError, ": ")
+			if _, err := tx.Exec("INSERT INTO run_errors VALUES (?, ?, ?)", id, channelID, message); err != nil {
+				return err
+			}
+		}
+		for _, channel := range run.Unavailable {
+			if _, err := tx.Exec("INSERT INTO run_unavailable VALUES (?, ?)", id, channel); err != nil {
+				return err
+			}
+		}
+	}
+	for account, channelID := range state.Accounts {
+		if _, err := tx.Exec("INSERT INTO accounts VALUES (?, ?)", account, channelID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("INSERT OR REPLACE INTO meta VALUES ('sourcesIncomplete', ?)", strconv.FormatBool(state.SourcesIncomplete)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO meta VALUES ('version', ?)", strconv.Itoa(stateVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// summarizeSQLiteState counts the channels of a SQLite state file by status
+// and reads its last run with queries, without loading the whole state.
+func summarizeSQLiteState(file string) (stateSummary, error) {
+	summary := stateSummary{}
+	db, err := openExistingSQLiteState(file)
+	if err != nil {
+		return summary, err
+	}
+	defer db.Close()
+
+	// Channels count as the first of imported, unavailable and skipped they
+	// are, like ChannelImportStatus does, and are pending otherwise
+	err = db.QueryRow(`SELECT COUNT(*),
+			COALESCE(SUM(imported), 0),
+			COALESCE(SUM(NOT imported AND unavailable), 0),
+			COALESCE(SUM(NOT imported AND NOT unavailable AND failure_permanent), 0),
+			COALESCE(SUM(NOT imported AND NOT unavailable AND NOT failure_permanent
+				AND (failure_error IS NOT NULL OR channel_id IN (SELECT channel_id FROM run_errors))), 0)
+		FROM channels`).Scan(&summary.total, &summary.imported, &summary.unavailable, &summary.skipped, &summary.failed)
+	if err != nil {
+		return summary, err
+	}
+	summary.pending = summary.total - summary.imported - summary.unavailable - summary.skipped
+
+	var started, finished string
+	var label sql.NullString
+	run := RunRecord{}
+	err = db.QueryRow("SELECT label, started, finished, imported, failed FROM runs ORDER BY id DESC LIMIT 1").
+		Scan(&label, &started, &finished, &run.Imported, &run.Failed)
+	if err == sql.ErrNoRows {
+		return summary, nil
+	} else if err != nil {
+		return summary, err
+	}
+	run.Label = label.String
+	run.Started, _ = time.Parse(time.RFC3339Nano, started)
+	run.Finished, _ = time.Parse(time.RFC3339Nano, finished)
+	summary.lastRun = &run
+	return summary, nil
+}
+
+// channelChange is a channel added to a state file, or whose status changed,
+// since some time.
+type channelChange struct {
+	channelID, title      string
+	imported, unavailable bool
+	// added is set if the channel was added since, rather than changed
+	added bool
+}
+
+// sqliteChangesSince returns the channels of a SQLite state file added or
+// changed since the time, in their order in the state file.
+func sqliteChangesSince(file string, since time.Time) ([]channelChange, error) {
+	db, err := openExistingSQLiteState(file)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// The times are kept in UTC in the same format, so they compare as text
+	sinceText := since.UTC().Format(time.RFC3339)
+	rows, err := db.Query(`SELECT channel_id, title, imported, unavailable, added_at >= ?
+		FROM channels WHERE added_at >= ? OR updated_at >= ? ORDER BY position`, sinceText, sinceText, sinceText)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []channelChange
+	for rows.Next() {
+		var change channelChange
+		var title sql.NullString
+		if err := rows.Scan(&change.channelID, &title, &change.imported, &change.unavailable, &change.added); err != nil {
+			return nil, err
+		}
+		change.title = title.String
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// sqliteRunStarted returns when the run of a SQLite state file numbered
+// number started, the last run's if number is 0.
+func sqliteRunStarted(file string, number int) (time.Time, error) {
+	db, err := openExistingSQLiteState(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer db.Close()
+
+	var started string
+	if number == 0 {
+		err = db.QueryRow("SELECT started FROM runs ORDER BY id DESC LIMIT 1").Scan(&started)
+	} else {
+		err = db.QueryRow("SELECT started FROM runs WHERE id = ?", number).Scan(&started)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, started)
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// defaultStateFile is where the import state is kept unless another file is
+// chosen.
+const defaultStateFile = "importStatus.json"
+
+// stateFile is where the import state is kept between runs.
+var stateFile = defaultStateFile
+
+// legacyStateFile is where the state was kept, gob encoded, before it was
+// kept as JSON. It is migrated to stateFile when that doesn't exist yet.
+const legacyStateFile = "importStatus.gob"
+
+// stateVersion is the version of the state file's format, increased when it
+// changes in a way older versions can't read.
+const stateVersion = 1
+
+// backupSuffix is appended to the state file's name for the copy of its
+// previous contents, kept to recover from a corrupt state file.
+const backupSuffix = ".bak"
+
+// errNewerState is returned for state files written by a newer version.
+var errNewerState = errors.New("the state file was written by a newer version of the tool")
+
+// versionedState is the state file's contents.
+type versionedState struct {
+	Version int `json:"version"`
+	*importState
+}
+
+// stateFileAccounts are the accounts whose channel IDs replace {source} and
+// {target} in the state file's name, so transfers between different pairs
+// of accounts each keep their own state file.
+var stateFileAccounts = []string{"source", "target"}
+
+// resolveStateFile replaces the {source} and {target} placeholders in the
+// name of a state file with the channel IDs of the accounts.
+func resolveStateFile(file string) (string, error) {
+	channels := accountChannels()
+	for _, account := range stateFileAccounts {
+		placeholder := "{" + account + "}"
+		if !strings.Contains(file, placeholder) {
+			continue
+		}
+		if channels[account] == "" {
+			return "", fmt.Errorf("the state file %s is named after the %s account's channel, which isn't known yet. Run auth login %s first", file, account, account)
+		}
+		file = strings.ReplaceAll(file, placeholder, channels[account])
+	}
+	return file, nil
+}
+
+// addStateFileFlag adds the -state-file flag to the flags of a command using
+// the state file.
+func addStateFileFlag(flags *flag.FlagSet) {
+	flags.StringVar(&stateFile, "state-file", stateFile, "file the transfer's progress is kept in, separate files keep separate transfers apart. {source} and {target} are replaced by the accounts' channel IDs")
+	addStateEncryptionFlags(flags)
+}
+
+// importState is everything persisted between runs in the state file.
+type importState struct {
+	Channels []ChannelImportStatus `json:"channels"`
+	Runs     []RunRecord           `json:"runs"`
+	// Accounts are the IDs of the channels picked for the source and target
+	// accounts
+	Accounts map[string]string `json:"accounts,omitempty"`
+	// SourcesIncomplete is set when listing the sources stopped partway, so
+	// the next transfer lists them again to add the channels missed
+	SourcesIncomplete bool `json:"sourcesIncomplete,omitempty"`
+}
+
+// subscriptionInsertCost is the quota units used by each subscribe call,
+// whether it succeeds or not.
+const subscriptionInsertCost = 50
+
+// subscriptionDeleteCost is the quota units used by each unsubscribe call.
+const subscriptionDeleteCost = 50
+
+// listCost is the quota units used by each call listing or looking up
+// channels and subscriptions.
+const listCost = 1
+
+// RunRecord describes a single run of the import and how it went.
+type RunRecord struct {
+	// ID tells the run apart from the runs of other state files, which are
+	// numbered the same, in the audit log
+	ID       string    `json:"id,omitempty"`
+	Label    string    `json:"label,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Imported int       `json:"imported"`
+	Failed   int       `json:"failed"`
+
+	// Errors are the errors channels failed with, in order
+	Errors []string `json:"errors,omitempty"`
+	// QuotaUsed is the quota units spent subscribing
+	QuotaUsed int `json:"quotaUsed"`
+	// QuotaExceeded is whether the run stopped because the quota ran out
+	QuotaExceeded bool `json:"quotaExceeded,omitempty"`
+	// Unavailable are the channels found to be unavailable to the target
+	// account, as "CHANNEL_ID TITLE"
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// newRunID returns the ID of a run of the state file started at started:
+// the state file's resolved path and the time.
+func newRunID(stateFile string, started time.Time) string {
+	if resolved, err := resolveStateFile(stateFile); err == nil {
+		stateFile = resolved
+	}
+	if absolute, err := filepath.Abs(stateFile); err == nil {
+		stateFile = absolute
+	}
+	return stateFile + "@" + started.UTC().Format(time.RFC3339Nano)
+}
+
+func (run RunRecord) String() string {
+	description := fmt.Sprintf("%s: %s imported, %s failed", formatDateTime(run.Started), formatCount(run.Imported), formatCount(run.Failed))
+	if run.Label != "" {
+		description += fmt.Sprintf(" (%s)", run.Label)
+	}
+	return description
+}
+
+// addChannels adds the channels that aren't in the state yet as pending and
+// returns how many were added.
+func (state *importState) addChannels(channels []*youtube.Subscription) int {
+	existing := make(map[string]bool)
+	for _, channelStatus := range state.Channels {
+		existing[channelStatus.Channel.Snippet.ResourceId.ChannelId] = true
+	}
+
+	added := 0
+	for _, channel := range channels {
+		channelID := channel.Snippet.ResourceId.ChannelId
+		if existing[channelID] {
+			continue
+		}
+		existing[channelID] = true
+		state.Channels = append(state.Channels, ChannelImportStatus{Channel: channel})
+		added++
+	}
+	return added
+}
+
+// tagChannels adds the tags to the channels in the state they are given
+// for by channel ID, and returns how many channels got new tags.
+func (state *importState) tagChannels(tags map[string][]string) int {
+	tagged := 0
+	for index := range state.Channels {
+		channelStatus := &state.Channels[index]
+		added := false
+		for _, tag := range tags[channelStatus.Channel.Snippet.ResourceId.ChannelId] {
+			known := false
+			for _, existing := range channelStatus.Tags {
+				known = known || existing == tag
+			}
+			if !known {
+				channelStatus.Tags = append(channelStatus.Tags, tag)
+				added = true
+			}
+		}
+		if added {
+			tagged++
+		}
+	}
+	return tagged
+}
+
+// channelTags returns the tags of the channels in the state by channel ID.
+func (state *importState) channelTags() map[string][]string {
+	tags := make(map[string][]string)
+	for _, channelStatus := range state.Channels {
+		if len(channelStatus.Tags) > 0 {
+			tags[channelStatus.Channel.Snippet.ResourceId.ChannelId] = channelStatus.Tags
+		}
+	}
+	return tags
+}
+
+// readStateFromFile decodes the state file. Gob encoded state files from
+// before the state was kept as JSON are still read, including those from
+// before runs were recorded, which only contain the channel statuses. If the
+// state file doesn't exist but the legacy one does, it is migrated. A
+// truncated or corrupt state file is replaced by its backup.
+func readStateFromFile(file string) (*importState, error) {
+	file, err := resolveStateFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if isSQLiteStateFile(file) {
+		return readSQLiteState(file)
+	}
+
+	state, err := decodeStateFile(file)
+	var keyError *stateKeyError
+	if err != nil && !os.IsNotExist(err) && !errors.Is(err, errNewerState) && !errors.As(err, &keyError) {
+		return recoverStateFile(file, err)
+	}
+	if !os.IsNotExist(err) || filepath.Base(file) != defaultStateFile {
+		return state, err
+	}
+
+	legacyFile := filepath.Join(filepath.Dir(file), legacyStateFile)
+	state, legacyErr := decodeStateFile(legacyFile)
+	if os.IsNotExist(legacyErr) {
+		return nil, err
+	} else if legacyErr != nil {
+		return nil, legacyErr
+	}
+
+	if stateEncrypted() {
+		fmt.Printf("Migrating %s to %s, the old file is removed as it isn't encrypted\n", legacyFile, file)
+	} else {
+		fmt.Printf("Migrating %s to %s, the old file is kept as a backup\n", legacyFile, file)
+	}
+	if err := writeStateToFile(file, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// recoverStateFile falls back to the backup of a state file that couldn't be
+// decoded, keeping the corrupt file aside to be looked at.
+func recoverStateFile(file string, err error) (*importState, error) {
+	backupFile := file + backupSuffix
+	state, backupErr := decodeStateFile(backupFile)
+	if backupErr != nil {
+		return nil, fmt.Errorf("%v, and its backup %s can't be read either: %v", err, backupFile, backupErr)
+	}
+
+	corruptFile := file + ".corrupt"
+	fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: %s is corrupt (%v), recovering the previous state from %s. The corrupt file is moved to %s", file, err, backupFile, corruptFile)))
+	if err := os.Rename(file, corruptFile); err != nil {
+		return nil, err
+	}
+	if err := writeStateToFile(file, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func decodeStateFile(file string) (*importState, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	start, err := reader.Peek(len(encryptedStateMagic))
+	if len(start) == 0 && err == io.EOF {
+		return nil, errors.New("the state file is empty")
+	} else if len(start) == 0 {
+		return nil, err
+	}
+	if bytes.HasPrefix(start, []byte(encryptedStateMagic)) {
+		encrypted, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		data, err := decryptStateData(encrypted)
+		if err != nil {
+			return nil, err
+		}
+		return decodeJSONState(bytes.NewReader(data))
+	}
+	if start[0] == '{' {
+		return decodeJSONState(reader)
+	}
+
+	state := &importState{}
+	if err := gob.NewDecoder(reader).Decode(state); err == nil {
+		return state, nil
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	if err := gob.NewDecoder(f).Decode(&state.Channels); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func decodeJSONState(r io.Reader) (*importState, error) {
+	versioned := versionedState{importState: &importState{}}
+	if err := json.NewDecoder(r).Decode(&versioned); err != nil {
+		return nil, err
+	}
+	if versioned.Version > stateVersion {
+		return nil, fmt.Errorf("%w: it is version %d, this version only reads up to version %d", errNewerState, versioned.Version, stateVersion)
+	}
+	return versioned.importState, nil
+}
+
+func writeStateToFile(file string, state *importState) error {
+	fmt.Println("Encoding state to file")
+	return saveState(file, state)
+}
+
+// saveState writes the state file like writeStateToFile, without saying so,
+// for checkpoints saved while channels are being imported.
+func saveState(file string, state *importState) error {
+	file, err := resolveStateFile(file)
+	if err != nil {
+		return err
+	}
+	if isSQLiteStateFile(file) {
+		if stateEncrypted() {
+			return errors.New("SQLite state files can't be encrypted, use a JSON state file")
+		}
+		return writeSQLiteState(file, state)
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(versionedState{Version: stateVersion, importState: state}); err != nil {
+		return err
+	}
+	if stateEncrypted() {
+		encrypted, err := encryptStateData(data.Bytes())
+		if err != nil {
+			return err
+		}
+		data = *bytes.NewBuffer(encrypted)
+	}
+
+	if err := backUpStateFile(file, stateEncrypted()); err != nil {
+		return err
+	}
+	if err := writeFileAtomically(file, 0600, func(w io.Writer) error {
+		_, err := data.WriteTo(w)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	// The legacy state file migrated from holds the same list in plain
+	if stateEncrypted() && filepath.Base(file) == defaultStateFile {
+		legacyFile := filepath.Join(filepath.Dir(file), legacyStateFile)
+		if err := os.Remove(legacyFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// backUpStateFile keeps the state file's current contents as its backup
+// before it is replaced. The backup is a hard link to the file where the
+// file system allows, so it costs nothing however large the state is. When
+// the state is being encrypted but the file isn't yet, the plain backup is
+// removed instead, to be replaced by an encrypted one on the next save.
+func backUpStateFile(file string, encrypting bool) error {
+	backupFile := file + backupSuffix
+	if err := os.Remove(backupFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if encrypting {
+		encrypted, err := isEncryptedFile(file)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !encrypted {
+			return nil
+		}
+	}
+	err := os.Link(file, backupFile)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeFileAtomically(backupFile, 0600, func(w io.Writer) error {
+		_, err := io.Copy(w, f)
+		return err
+	})
+}
+
+// autosaver flushes the state file after every N processed channels and/or
+// once a given amount of time has passed since the last flush, so a crash
+// loses at most that much progress. Zero values disable either trigger.
+type autosaver struct {
+	saveState func() error
+	every     int
+	interval  time.Duration
+	processed int
+	lastSave  time.Time
+}
+
+func newAutosaver(saveState func() error, every int, interval time.Duration) *autosaver {
+	return &autosaver{saveState: saveState, every: every, interval: interval, lastSave: time.Now()}
+}
+
+// channelProcessed records that a channel changed and saves the state if
+// either trigger has been reached.
+func (a *autosaver) channelProcessed() error {
+	a.processed++
+
+	if (a.every > 0 && a.processed >= a.every) || (a.interval > 0 && time.Since(a.lastSave) >= a.interval) {
+		return a.save()
+	}
+	return nil
+}
+
+func (a *autosaver) save() error {
+	a.processed = 0
+	a.lastSave = time.Now()
+	return a.saveState()
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// encryptedStateMagic starts an encrypted state file. It is followed by the
+// salt the key is derived with, a check of the key, the nonce and the
+// AES-GCM encrypted JSON.
+const encryptedStateMagic = "youtube-subscriptions-transfer encrypted state 1\n"
+
+const (
+	stateSaltSize     = 16
+	stateKeyCheckSize = 16
+)
+
+// errWrongStateKey is returned for an encrypted state file that the given
+// key file or passphrase doesn't open.
+var errWrongStateKey = errors.New("the key file or passphrase doesn't match the encrypted state file")
+
+// stateKeyError is an error getting the secret an encrypted state file is
+// opened with, which says nothing about the file being damaged.
+type stateKeyError struct {
+	err error
+}
+
+func (e *stateKeyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stateKeyError) Unwrap() error {
+	return e.err
+}
+
+// encryptState encrypts the state file at rest, with the key file if given,
+// otherwise with a passphrase.
+var encryptState bool
+
+// stateKeyFile holds the secret the state file is encrypted with, instead
+// of a passphrase.
+var stateKeyFile string
+
+// stateSecret is the key file's contents or the passphrase, once read.
+var stateSecret []byte
+
+// askStatePassphrase is whether the passphrase may be asked for on the
+// terminal, which completion can't do.
+var askStatePassphrase = true
+
+// addStateEncryptionFlags adds the flags encrypting the state file to the
+// flags of a command using it.
+func addStateEncryptionFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&encryptState, "encrypt-state", encryptState, "encrypt the state file with a passphrase, asked for or read from "+envName("state-passphrase"))
+	flags.StringVar(&stateKeyFile, "state-key-file", stateKeyFile, "file holding the secret the state file is encrypted with, instead of a passphrase")
+}
+
+// stateEncrypted reports whether the state file is to be written encrypted.
+func stateEncrypted() bool {
+	return encryptState || stateKeyFile != "" || stateSecret != nil
+}
+
+// readStateSecret returns the key file's contents or the passphrase,
+// asking for the passphrase on the terminal if it isn't in the environment.
+// A new passphrase is asked for twice.
+func readStateSecret(confirmNew bool) ([]byte, error) {
+	if stateSecret != nil {
+		return stateSecret, nil
+	}
+
+	switch {
+	case stateKeyFile != "":
+		secret, err := ioutil.ReadFile(expandHome(stateKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		stateSecret = bytes.TrimRight(secret, "\r\n")
+	case os.Getenv(envName("state-passphrase")) != "":
+		stateSecret = []byte(os.Getenv(envName("state-passphrase")))
+	case askStatePassphrase && term.IsTerminal(int(os.Stdin.Fd())):
+		passphrase, err := readPassphrase("State file passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if confirmNew {
+			again, err := readPassphrase("Repeat the passphrase: ")
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(passphrase, again) {
+				return nil, errors.New("the passphrases don't match")
+			}
+		}
+		stateSecret = passphrase
+	default:
+		return nil, fmt.Errorf("the state file is encrypted, pass -state-key-file or set %s", envName("state-passphrase"))
+	}
+
+	if len(stateSecret) == 0 {
+		stateSecret = nil
+		return nil, errors.New("the state file's passphrase or key file is empty")
+	}
+	return stateSecret, nil
+}
+
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return passphrase, err
+}
+
+// isEncryptedFile reports whether a file is encrypted with the state's
+// secret.
+func isEncryptedFile(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	start := make([]byte, len(encryptedStateMagic))
+	n, err := io.ReadFull(f, start)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return string(start[:n]) == encryptedStateMagic, nil
+}
+
+// stateKey derives the encryption key and its check from the secret.
+func stateKey(secret, salt []byte) (key, check []byte, err error) {
+	key, err = scrypt.Key(secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(append([]byte("check"), key...))
+	return key, sum[:stateKeyCheckSize], nil
+}
+
+// encryptStateData encrypts the encoded state with the secret.
+func encryptStateData(data []byte) ([]byte, error) {
+	secret, err := readStateSecret(true)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, stateSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, check, err := stateKey(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newStateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	encrypted := []byte(encryptedStateMagic)
+	encrypted = append(encrypted, salt...)
+	encrypted = append(encrypted, check...)
+	encrypted = append(encrypted, nonce...)
+	return aead.Seal(encrypted, nonce, data, []byte(encryptedStateMagic)), nil
+}
+
+// decryptStateData decrypts an encrypted state file's contents. A wrong
+// secret gives errWrongStateKey, a damaged file another error.
+func decryptStateData(encrypted []byte) ([]byte, error) {
+	encrypted = encrypted[len(encryptedStateMagic):]
+	if len(encrypted) < stateSaltSize+stateKeyCheckSize {
+		return nil, errors.New("the encrypted state file is truncated")
+	}
+	salt := encrypted[:stateSaltSize]
+	storedCheck := encrypted[stateSaltSize : stateSaltSize+stateKeyCheckSize]
+	encrypted = encrypted[stateSaltSize+stateKeyCheckSize:]
+
+	secret, err := readStateSecret(false)
+	if err != nil {
+		return nil, &stateKeyError{err}
+	}
+	key, check, err := stateKey(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(check, storedCheck) != 1 {
+		stateSecret = nil
+		return nil, &stateKeyError{errWrongStateKey}
+	}
+
+	aead, err := newStateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errors.New("the encrypted state file is truncated")
+	}
+	nonce, ciphertext := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(encryptedStateMagic))
+}
+
+func newStateCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
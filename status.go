@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// statusCommand summarizes the transfer's progress from the state file,
+// without calling the API.
+func statusCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	dailyQuota := flags.Int("daily-quota", defaultDailyQuota, "daily quota units of the API project, for estimating the runs left")
+	addStateFileFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s status [FLAGS]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	return flags, func(args []string) {
+		if flags.NArg() > 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		summary, err := readStateSummary(stateFile)
+		if os.IsNotExist(err) {
+			fmt.Println("No transfer has been started yet")
+			return
+		} else if err != nil {
+			log.Fatalf("Unable to read state file: %v", err)
+		}
+
+		fmt.Printf("Imported:     %s of %s channels\n", formatCount(summary.imported), formatCount(summary.total))
+		fmt.Printf("Pending:      %s\n", formatCount(summary.pending))
+		if summary.failed > 0 {
+			fmt.Printf("Failed:       %s of the pending channels failed in earlier runs\n", formatCount(summary.failed))
+		}
+		if summary.unavailable > 0 {
+			fmt.Printf("Unavailable:  %s\n", formatCount(summary.unavailable))
+		}
+		if summary.skipped > 0 {
+			fmt.Printf("Skipped:      %s failed for good, such as deleted channels\n", formatCount(summary.skipped))
+		}
+		if summary.lastRun != nil {
+			fmt.Printf("Last run:     %v\n", *summary.lastRun)
+		}
+		if summary.pending > 0 && *dailyQuota >= subscriptionInsertCost {
+			perRun := *dailyQuota / subscriptionInsertCost
+			fmt.Printf("Remaining:    about %s daily runs at %s channels a run\n", formatCount((summary.pending+perRun-1)/perRun), formatCount(perRun))
+		}
+	}
+}
+
+// stateSummary counts the channels in a state file by their status.
+type stateSummary struct {
+	total, imported, pending, unavailable, skipped int
+	// failed counts the pending channels that failed in earlier runs
+	failed  int
+	lastRun *RunRecord
+}
+
+// readStateSummary summarizes the state file. A SQLite state file is
+// summarized with queries instead of loading it.
+func readStateSummary(file string) (stateSummary, error) {
+	resolved, err := resolveStateFile(file)
+	if err != nil {
+		return stateSummary{}, err
+	}
+	if isSQLiteStateFile(resolved) {
+		return summarizeSQLiteState(resolved)
+	}
+
+	state, err := readStateFromFile(file)
+	if err != nil {
+		return stateSummary{}, err
+	}
+	return summarizeState(state), nil
+}
+
+// summarizeState counts the state's channels by status.
+func summarizeState(state *importState) stateSummary {
+	summary := stateSummary{total: len(state.Channels)}
+	failedChannels := failedChannelIDs(state.Runs)
+	for _, channelStatus := range state.Channels {
+		switch {
+		case channelStatus.Imported:
+			summary.imported++
+		case channelStatus.Unavailable:
+			summary.unavailable++
+		case channelStatus.skipped():
+			summary.skipped++
+		default:
+			summary.pending++
+			if channelStatus.Failure != nil || failedChannels[channelStatus.Channel.Snippet.ResourceId.ChannelId] {
+				summary.failed++
+			}
+		}
+	}
+	if len(state.Runs) > 0 {
+		summary.lastRun = &state.Runs[len(state.Runs)-1]
+	}
+	return summary
+}
+
+// failedChannelIDs returns the IDs of the channels the runs recorded errors
+// for. The IDs are the ones subscribed to, after any remapping.
+func failedChannelIDs(runs []RunRecord) map[string]bool {
+	failed := make(map[string]bool)
+	for _, run := range runs {
+		for _, runError := range run.Errors {
+			if channelID, _, ok := strings.Cut(runError, ": "); ok {
+				failed[channelID] = true
+			}
+		}
+	}
+	return failed
+}
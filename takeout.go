@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// takeoutCSVHeader are the columns of subscriptions.csv in a Google Takeout
+// YouTube export. The header is in the account's language, the columns are
+// always in this order.
+var takeoutCSVHeader = []string{"Channel Id", "Channel Url", "Channel Title"}
+
+// exportToCSV writes the subscriptions as a CSV file in the format of
+// subscriptions.csv in a Google Takeout YouTube export, which other tools
+// reading Takeout files take, and import -from csv reads back.
+func exportToCSV(ctx context.Context, subscriptions []*youtube.Subscription, options exportOptions) error {
+	return writeExport(options.outputOr("subscriptions.csv"), func(f *os.File) error {
+		writer := csv.NewWriter(f)
+		if err := writer.Write(takeoutCSVHeader); err != nil {
+			return err
+		}
+		for _, subscription := range subscriptions {
+			channelID := subscription.Snippet.ResourceId.ChannelId
+			// Takeout links channels over http
+			row := []string{channelID, "http://www.youtube.com/channel/" + channelID, subscription.Snippet.Title}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+// readTakeoutCSV finds the channels in the subscriptions.csv of a Google
+// Takeout YouTube export, so the source account doesn't need authorizing.
+func readTakeoutCSV(file string, options importOptions) ([]channelReference, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return takeoutCSVReferences(f)
+}
+
+// takeoutCSVReferences reads the channels in a Takeout subscriptions.csv by
+// the position of the columns, whatever language the header is in. Rows
+// without a channel, such as the header, are skipped.
+func takeoutCSVReferences(r io.Reader) ([]channelReference, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var references []channelReference
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		channelID := strings.TrimSpace(strings.TrimPrefix(row[0], "\ufeff"))
+		// The second column links to the channel, which tells the
+		// subscriptions apart from other Takeout files starting with a
+		// channel ID
+		if !channelIDPattern.MatchString(channelID) || len(row) > 1 && !strings.Contains(row[1], channelID) {
+			continue
+		}
+		reference := channelReference{id: channelID}
+		if len(row) > 2 {
+			reference.title = strings.TrimSpace(row[2])
+		}
+		references = append(references, reference)
+	}
+	return references, nil
+}
+
+// readTakeoutZip finds the channels in the subscriptions.csv inside a Google
+// Takeout archive. The folders and the file are named in the account's
+// language, so when there is no subscriptions/subscriptions.csv the first
+// CSV file laid out like one is used.
+func readTakeoutZip(file string, options importOptions) ([]channelReference, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var candidates []*zip.File
+	for _, f := range archive.File {
+		name := strings.ToLower(f.Name)
+		if strings.HasSuffix(name, "/subscriptions/subscriptions.csv") {
+			candidates = append([]*zip.File{f}, candidates...)
+		} else if strings.HasSuffix(name, ".csv") {
+			candidates = append(candidates, f)
+		}
+	}
+
+	for _, f := range candidates {
+		references, err := readTakeoutZipFile(f)
+		if err != nil {
+			// Other Takeout CSV files needn't parse as the subscriptions do
+			continue
+		}
+		if len(references) > 0 {
+			fmt.Printf("Reading subscriptions from %s\n", f.Name)
+			return references, nil
+		}
+	}
+	return nil, fmt.Errorf("no subscriptions.csv found in %s, is it a Takeout export including the YouTube subscriptions?", file)
+}
+
+func readTakeoutZipFile(f *zip.File) ([]channelReference, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return takeoutCSVReferences(r)
+}
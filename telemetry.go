@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+const instrumentationName = "youtube-subscriptions-transfer"
+
+// tracer and meter are no-ops until setUpTelemetry installs providers.
+var tracer = otel.Tracer(instrumentationName)
+var meter = otel.Meter(instrumentationName)
+
+// channelsProcessed counts the channels the transfer tried to subscribe to,
+// by result, and quotaUnitsUsed the quota units spent doing so.
+var channelsProcessed, _ = meter.Int64Counter("transfer.channels",
+	metric.WithDescription("Channels the transfer tried to subscribe to, by result"))
+var quotaUnitsUsed, _ = meter.Int64Counter("transfer.quota.units",
+	metric.WithDescription("YouTube API quota units spent subscribing"))
+
+// setUpTelemetry sends traces and metrics over OTLP/HTTP when an OTLP
+// endpoint is configured with the standard OTEL_EXPORTER_OTLP_* environment
+// variables, and returns a function flushing them. Without one it does
+// nothing.
+func setUpTelemetry(ctx context.Context) func() {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" &&
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" &&
+		os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT") == "" {
+		return func() {}
+	}
+
+	traceExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		log.Fatalf("Unable to set up trace exporter: %v", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		log.Fatalf("Unable to set up metric exporter: %v", err)
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(instrumentationName))
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+
+	return func() {
+		if err := tracerProvider.Shutdown(context.Background()); err != nil {
+			log.Printf("Unable to flush traces: %v", err)
+		}
+		if err := meterProvider.Shutdown(context.Background()); err != nil {
+			log.Printf("Unable to flush metrics: %v", err)
+		}
+	}
+}
+
+// tracedTransport records a span for each API request sent through base.
+func tracedTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// startSpan starts a span as a child of any span in ctx.
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+// errAuthorizationExpired is returned for calls made with credentials whose
+// refresh token has expired or been revoked when there is no one to
+// authorize the account again.
+var errAuthorizationExpired = errors.New("the account's authorization has expired or been revoked")
+
+// refreshableTokenSource hands out the cached access token until it expires
+// or is invalidated, then refreshes it. If the refresh token itself has
+// expired or been revoked, the account is authorized again with reauthorize.
+type refreshableTokenSource struct {
+	ctx         context.Context
+	config      *oauth2.Config
+	reauthorize func() (*oauth2.Token, error)
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func (source *refreshableTokenSource) Token() (*oauth2.Token, error) {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	if source.token.Valid() {
+		return source.token, nil
+	}
+
+	token, err := source.config.TokenSource(source.ctx, source.token).Token()
+	if isInvalidGrant(err) && source.reauthorize != nil {
+		token, err = source.reauthorize()
+	}
+	if err != nil {
+		return nil, err
+	}
+	source.token = token
+	return token, nil
+}
+
+// isInvalidGrant reports whether refreshing a token failed because the
+// refresh token has expired or been revoked. Refresh tokens of OAuth apps in
+// testing mode expire after 7 days.
+func isInvalidGrant(err error) bool {
+	var retrieveError *oauth2.RetrieveError
+	return errors.As(err, &retrieveError) && retrieveError.ErrorCode == "invalid_grant"
+}
+
+// invalidate makes the next call to Token refresh the access token.
+func (source *refreshableTokenSource) invalidate() {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	expired := *source.token
+	expired.Expiry = time.Now().Add(-time.Minute)
+	source.token = &expired
+}
+
+// reauthorizingTransport authorizes requests with the token source. When an
+// access token is rejected before its expiry, for example in the middle of
+// paging through a long listing, it refreshes the token and tries once more.
+type reauthorizingTransport struct {
+	source *refreshableTokenSource
+	base   http.RoundTripper
+}
+
+func newAuthorizedClient(ctx context.Context, config *oauth2.Config, token *oauth2.Token, reauthorize func() (*oauth2.Token, error)) *http.Client {
+	source := &refreshableTokenSource{ctx: ctx, config: config, token: token, reauthorize: reauthorize}
+	return &http.Client{
+		Transport: tracedTransport(&reauthorizingTransport{
+			source: source,
+			base:   &oauth2.Transport{Source: source},
+		}),
+	}
+}
+
+func (transport *reauthorizingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	retry := request.Clone(request.Context())
+
+	response, err := transport.base.RoundTrip(request)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	// A request body can only be sent again if it can be recreated
+	if request.Body != nil && request.Body != http.NoBody {
+		if request.GetBody == nil {
+			return response, nil
+		}
+		body, err := request.GetBody()
+		if err != nil {
+			return response, nil
+		}
+		retry.Body = body
+	}
+
+	response.Body.Close()
+	transport.source.invalidate()
+
+	return transport.base.RoundTrip(retry)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+)
+
+// keyringService is the service tokens are stored under in the keyring.
+const keyringService = "youtube-subscriptions-transfer"
+
+// useKeyring keeps tokens in the system keyring (macOS Keychain, GNOME
+// Keyring or another Secret Service, Windows Credential Manager) instead of
+// files, set with -keyring. Files are still used when there is no keyring.
+var useKeyring bool
+
+// loadToken returns the cached token of an account, from the keyring if it
+// is used and holds one, otherwise from the credentials directory. A token
+// still in a file is moved into the keyring.
+func loadToken(name string) (*oauth2.Token, error) {
+	if useKeyring {
+		secret, err := keyring.Get(keyringService, name)
+		if err == nil {
+			token := &oauth2.Token{}
+			return token, json.Unmarshal([]byte(secret), token)
+		} else if !errors.Is(err, keyring.ErrNotFound) {
+			fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to read the keyring, using the credentials directory: %v", err)))
+		}
+	}
+
+	cacheFile, err := tokenCacheFile(name)
+	if err != nil {
+		return nil, err
+	}
+	token, err := tokenFromFile(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if useKeyring && storeTokenInKeyring(name, token) == nil {
+		fmt.Printf("Moved the %s account's credentials into the keyring\n", name)
+		os.Remove(cacheFile)
+	}
+	return token, nil
+}
+
+// storeToken caches the token of an account, in the keyring if it is used
+// and available, otherwise in the credentials directory.
+func storeToken(name string, token *oauth2.Token) {
+	if useKeyring {
+		err := storeTokenInKeyring(name, token)
+		if err == nil {
+			fmt.Printf("Saved the %s account's credentials in the keyring\n", name)
+			return
+		}
+		fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to use the keyring, saving the credentials to a file: %v", err)))
+	}
+
+	cacheFile, err := tokenCacheFile(name)
+	if err != nil {
+		log.Fatalf("Unable to get path to cached credential file. %v", err)
+	}
+	saveToken(cacheFile, token)
+}
+
+func storeTokenInKeyring(name string, token *oauth2.Token) error {
+	secret, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(keyringService, name, string(secret))
+}
+
+// deleteToken removes the cached token of an account from the keyring, if it
+// is used, and the credentials directory.
+func deleteToken(name string) error {
+	if useKeyring {
+		if err := keyring.Delete(keyringService, name); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return err
+		}
+	}
+
+	cacheFile, err := tokenCacheFile(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
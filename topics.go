@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// maxChannelsPerRequest is how many channel IDs the API accepts per call.
+const maxChannelsPerRequest = 50
+
+// channelTopics looks up the topics YouTube has assigned to each channel,
+// returning their readable names keyed by channel ID.
+func channelTopics(ctx context.Context, service *youtube.Service, channelIDs []string) (map[string][]string, error) {
+	topics := make(map[string][]string)
+
+	for start := 0; start < len(channelIDs); start += maxChannelsPerRequest {
+		end := start + maxChannelsPerRequest
+		if end > len(channelIDs) {
+			end = len(channelIDs)
+		}
+
+		response, err := service.Channels.List([]string{"topicDetails"}).Id(channelIDs[start:end]...).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, channel := range response.Items {
+			if channel.TopicDetails == nil {
+				continue
+			}
+			for _, topicURL := range channel.TopicDetails.TopicCategories {
+				topics[channel.Id] = append(topics[channel.Id], topicName(topicURL))
+			}
+		}
+	}
+
+	return topics, nil
+}
+
+// topicName turns a topic category such as
+// https://en.wikipedia.org/wiki/Video_game_culture into "Video game culture".
+func topicName(topicURL string) string {
+	name := path.Base(topicURL)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return strings.ReplaceAll(name, "_", " ")
+}
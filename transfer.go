@@ -0,0 +1,575 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// rateLimiter paces inserts: Wait blocks until the next insert may be made.
+type rateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// noRateLimit lets every insert through right away.
+type noRateLimit struct{}
+
+func (noRateLimit) Wait(ctx context.Context) error {
+	return nil
+}
+
+// fixedDelay lets an insert through once delay has passed since the last
+// one was let through.
+type fixedDelay struct {
+	delay time.Duration
+	last  time.Time
+}
+
+// newFixedDelay returns a rateLimiter keeping inserts delay apart, or one
+// not limiting them if delay isn't positive.
+func newFixedDelay(delay time.Duration) rateLimiter {
+	if delay <= 0 {
+		return noRateLimit{}
+	}
+	return &fixedDelay{delay: delay}
+}
+
+func (limiter *fixedDelay) Wait(ctx context.Context) error {
+	if wait := time.Until(limiter.last.Add(limiter.delay)); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	limiter.last = time.Now()
+	return nil
+}
+
+// transferOptions configure a Transferer.
+type transferOptions struct {
+	channelMap           map[string]string
+	quotaResetLocation   *time.Location
+	maxIdenticalFailures int
+
+	// clock returns the current time, time.Now if nil
+	clock func() time.Time
+	// limiter is waited on before each insert, no limit if nil
+	limiter rateLimiter
+	// ledger is where quota is reserved before each insert, if not nil
+	ledger *quotaLedger
+	// auditLog is where each insert is logged, if not nil
+	auditLog *auditLog
+	// retries is how failing inserts are retried, not at all if zero
+	retries retryPolicy
+
+	// subscribedAfter and subscribedBefore, if not zero, leave channels
+	// subscribed to outside that range on the source account pending
+	subscribedAfter, subscribedBefore time.Time
+	// limit, if not zero, stops the run after this many channels have been
+	// subscribed to
+	limit int
+	// maxQuotaUnits, if not zero, stops the run before a call would take
+	// the quota it used over this many units
+	maxQuotaUnits int
+	// quotaUsedBefore is quota already spent for the next run before it
+	// started, such as on listing the target account's subscriptions. It
+	// is counted in that run's quota use and against maxQuotaUnits
+	quotaUsedBefore int
+
+	// skip is called right before subscribing to each channel and reports
+	// whether to leave it pending for now
+	skip func() bool
+	// processed is called after each channel an insert was attempted for
+	processed func(channel *youtube.Subscription)
+}
+
+// Transferer subscribes a target account to the pending channels of an
+// import state, saving it to its state file. Its state is guarded by a lock
+// and it shares nothing with other Transferers, so several can run in one
+// process and their progress can be read while they run.
+type Transferer struct {
+	target    *youtube.Service
+	stateFile string
+	options   transferOptions
+
+	mu      sync.Mutex
+	state   *importState
+	running bool
+}
+
+func newTransferer(target *youtube.Service, stateFile string, state *importState, options transferOptions) *Transferer {
+	if options.clock == nil {
+		options.clock = time.Now
+	}
+	if options.limiter == nil {
+		options.limiter = noRateLimit{}
+	}
+	if options.quotaResetLocation == nil {
+		options.quotaResetLocation, _ = time.LoadLocation(defaultQuotaResetTimeZone)
+	}
+
+	return &Transferer{target: target, stateFile: stateFile, state: state, options: options}
+}
+
+// SetTarget makes the following runs subscribe with target, such as the
+// target account authorized against another API project.
+func (transferer *Transferer) SetTarget(target *youtube.Service) {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	transferer.target = target
+}
+
+// Progress returns how many of the channels have been imported, leaving
+// out channels unavailable to the target account or failing permanently.
+func (transferer *Transferer) Progress() (imported, total int) {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	for _, channelStatus := range transferer.state.Channels {
+		if channelStatus.skipped() {
+			continue
+		}
+		total++
+		if channelStatus.Imported {
+			imported++
+		}
+	}
+	return imported, total
+}
+
+// Save writes the state to the state file.
+func (transferer *Transferer) Save() error {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	return writeStateToFile(transferer.stateFile, transferer.state)
+}
+
+// Checkpoint writes the state to the state file while channels are being
+// imported, so a crash doesn't lose which were already imported.
+func (transferer *Transferer) Checkpoint() error {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	return saveState(transferer.stateFile, transferer.state)
+}
+
+// errRunBudgetSpent is why a call isn't made when it would take the run's
+// quota use over -max-quota-units.
+var errRunBudgetSpent = errors.New("the run's -max-quota-units budget has been spent")
+
+// quotaSpendError is why a call wasn't made: its quota didn't fit in the
+// run's budget or couldn't be reserved in the shared ledger. It isn't
+// retried.
+type quotaSpendError struct {
+	err error
+}
+
+func (e *quotaSpendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *quotaSpendError) Unwrap() error {
+	return e.err
+}
+
+// Run subscribes to the pending channels one by one until all have been
+// tried, the quota is exceeded, the target account keeps failing or ctx is
+// done. The run is recorded in the state with label, and the state saved.
+// A Transferer can be run again, but not while it is already running.
+func (transferer *Transferer) Run(ctx context.Context, label string) (RunRecord, error) {
+	transferer.mu.Lock()
+	if transferer.running {
+		transferer.mu.Unlock()
+		return RunRecord{}, errors.New("the transfer is already running")
+	}
+	transferer.running = true
+	channelStatuses := transferer.state.Channels
+	runNumber := len(transferer.state.Runs) + 1
+	targetChannel := transferer.state.Accounts["target"]
+	quotaUsedBefore := transferer.options.quotaUsedBefore
+	transferer.options.quotaUsedBefore = 0
+	transferer.mu.Unlock()
+	if targetChannel == "" {
+		// Pipelines don't record the target channel in the state
+		targetChannel = readAccountChannels()["target"]
+	}
+
+	defer func() {
+		transferer.mu.Lock()
+		transferer.running = false
+		transferer.mu.Unlock()
+	}()
+
+	ctx, runSpan := startSpan(ctx, "transfer", attribute.String("label", label))
+	defer runSpan.End()
+	// Requests aren't cancelled with ctx, stopping only happens between
+	// channels, but are traced as part of the run
+	requestCtx := trace.ContextWithSpan(context.Background(), runSpan)
+
+	options := transferer.options
+	run := RunRecord{Label: label, Started: options.clock()}
+	run.ID = newRunID(transferer.stateFile, run.Started)
+	run.QuotaUsed = quotaUsedBefore
+	quotaUnitsUsed.Add(requestCtx, int64(quotaUsedBefore))
+	failures := &failureTracker{limit: options.maxIdenticalFailures}
+	// spend counts a call's quota in the run and reserves it in the shared
+	// ledger, once it is known to fit in both, so the call can be made
+	spend := func(cost int) error {
+		if options.maxQuotaUnits > 0 && run.QuotaUsed+cost > options.maxQuotaUnits {
+			return &quotaSpendError{errRunBudgetSpent}
+		}
+		if options.ledger != nil {
+			if err := options.ledger.reserve(cost); err != nil {
+				return &quotaSpendError{err}
+			}
+		}
+		run.QuotaUsed += cost
+		quotaUnitsUsed.Add(requestCtx, int64(cost))
+		return nil
+	}
+	// refused are the failures of the channels skipped for refusing
+	// subscriptions, in order
+	var refused []*channelFailure
+
+	fmt.Printf("Importing up to %s unimported channels 1 by 1\n", formatCount(len(channelStatuses)))
+channels:
+	for index, channelStatus := range channelStatuses {
+		if ctx.Err() != nil {
+			break
+		}
+		if options.limit > 0 && run.Imported >= options.limit {
+			fmt.Printf("Subscribed to %s channels, the -limit for this run. Stopping\n", formatCount(run.Imported))
+			break
+		}
+
+		channel := channelStatus.Channel
+
+		channelID := channel.Snippet.ResourceId.ChannelId
+		if newChannelID, ok := options.channelMap[channelID]; ok {
+			channelID = newChannelID
+		}
+
+		channelToSubscribeTo := &youtube.Subscription{
+			Snippet: &youtube.SubscriptionSnippet{
+				ResourceId: &youtube.ResourceId{
+					ChannelId: channelID,
+					Kind:      "youtube#channel",
+				},
+			},
+		}
+
+		line := startStatusLine(fmt.Sprintf("Attempting to add channel %s: %s: ", channelNumber(index, len(channelStatuses)), displayTitle(channel.Snippet.Title, titleWidth)))
+
+		if channelID != channel.Snippet.ResourceId.ChannelId {
+			line.print(fmt.Sprintf("(remapped to %s) ", channelID))
+		}
+
+		if channelStatus.Imported {
+			line.finish(colorYellow, "already imported, skipping")
+			continue
+		}
+
+		if channelStatus.Unavailable {
+			line.finish(colorYellow, "unavailable to the target account, skipping")
+			continue
+		}
+
+		if channelStatus.skipped() {
+			line.finish(colorYellow, fmt.Sprintf("failed permanently in an earlier run, skipping (%s)", channelStatus.Failure.Error))
+			continue
+		}
+
+		if !subscribedWithin(channel, options.subscribedAfter, options.subscribedBefore) {
+			line.finish(colorYellow, "subscribed to outside the chosen dates, leaving it pending")
+			continue
+		}
+
+		if options.maxQuotaUnits > 0 && run.QuotaUsed+subscriptionInsertCost > options.maxQuotaUnits {
+			line.finish(colorYellow, "leaving it pending")
+			imported, total := transferer.Progress()
+			fmt.Printf("Subscribing would take the run over its -max-quota-units budget, it used %s of %s quota units. Stopping with %s channels left pending\n",
+				formatCount(run.QuotaUsed), formatCount(options.maxQuotaUnits), formatCount(total-imported))
+			break
+		}
+
+		if err := options.limiter.Wait(ctx); err != nil {
+			line.finish(colorYellow, "stopping")
+			break
+		}
+
+		// Checked last, so a key pressed while the channel's line shows
+		// skips that channel
+		if options.skip != nil && options.skip() {
+			line.finish(colorYellow, "skipped, leaving it pending")
+			continue
+		}
+
+		spanCtx, span := startSpan(requestCtx, "subscribe", attribute.String("channel.id", channelID))
+		// Failed calls use quota too, so every attempt is counted, and
+		// retrying stops once the next attempt doesn't fit in the budget. A
+		// retry after an insert that went through anyway fails as a
+		// duplicate
+		var subscription *youtube.Subscription
+		attempts := 0
+		var lastErr error
+		_, err := options.retries.do(ctx, func() error {
+			if err := spend(subscriptionInsertCost); err != nil {
+				return err
+			}
+			attempts++
+			subscription, lastErr = transferer.target.Subscriptions.Insert([]string{"snippet"}, channelToSubscribeTo).Context(spanCtx).Do()
+			return lastErr
+		}, func(err error, delay time.Duration) {
+			line.print(fmt.Sprintf("(failed, retrying in %v: %v) ", delay.Round(time.Millisecond), err))
+		})
+		span.SetAttributes(attribute.Int("attempts", attempts))
+		result := "imported"
+
+		audit := func(result string) {
+			entry := auditEntry{Action: "subscribe", Account: "target", AccountChannel: targetChannel, Run: runNumber, RunID: run.ID,
+				ChannelID: channelID, Title: channel.Snippet.Title, Result: result, QuotaCost: attempts * subscriptionInsertCost}
+			if subscription != nil {
+				entry.SubscriptionID = subscription.Id
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if err := options.auditLog.record(entry); err != nil {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Warning: unable to write to the audit log: %v", err)))
+			}
+		}
+
+		var notSpent *quotaSpendError
+		if errors.As(err, &notSpent) {
+			if notSpent.err == errQuotaBudgetSpent {
+				run.QuotaExceeded = true
+			}
+			switch {
+			case attempts == 0 && notSpent.err == errQuotaBudgetSpent:
+				line.finish(colorRed, "the shared daily quota budget has been spent by this and other instances. Stopping")
+			case attempts == 0:
+				// The run's own budget was checked above, so this is the
+				// ledger
+				line.finish(colorRed, fmt.Sprintf("unable to reserve quota: %v. Stopping", notSpent.err))
+			default:
+				line.finish(colorYellow, fmt.Sprintf("failed, and retrying isn't possible as %v, leaving it pending (%v)", notSpent.err, lastErr))
+				err = lastErr
+				audit("budgetSpent")
+			}
+			if notSpent.err == errRunBudgetSpent {
+				imported, total := transferer.Progress()
+				fmt.Printf("The run used %s of its %s quota units. Stopping with %s channels left pending\n",
+					formatCount(run.QuotaUsed), formatCount(options.maxQuotaUnits), formatCount(total-imported))
+			}
+			span.End()
+			break
+		}
+
+		var failure *channelFailure
+		if err != nil {
+			failure = &channelFailure{Time: options.clock(), Reason: errorReason(err), Error: err.Error()}
+		}
+
+		reason := errorReason(err)
+		switch {
+		case err == nil:
+			line.finish(colorGreen, "successfully subscribed to channel")
+			transferer.recordAttempt(index, nil)
+			transferer.setImported(index)
+			run.Imported++
+			failures.succeeded()
+
+		case reason == "subscriptionDuplicate":
+			line.finish(colorYellow, fmt.Sprintf("previously subscribed, marking as imported (%v)", err))
+			transferer.recordAttempt(index, nil)
+			transferer.setImported(index)
+			failures.succeeded()
+			result = "duplicate"
+
+		case reason == "quotaExceeded" || reason == "dailyLimitExceeded":
+			now := options.clock()
+			quotaReset := nextQuotaReset(now, options.quotaResetLocation)
+			line.finish(colorRed, fmt.Sprintf("quota exceeded, can't import any more until the quota resets at %s (in %v). Stopping",
+				formatDateTime(quotaReset.Local())+quotaReset.Local().Format(" MST"), quotaReset.Sub(now).Round(time.Minute)))
+			run.QuotaExceeded = true
+			audit("quotaExceeded")
+			span.End()
+			break channels
+
+		case reason == "rateLimitExceeded" || reason == "userRateLimitExceeded":
+			line.finish(colorRed, fmt.Sprintf("subscribing too fast, leaving it pending. Stopping, run again later (%v)", err))
+			audit("rateLimited")
+			span.End()
+			break channels
+
+		case isTargetAccountProblem(err):
+			// Every other channel would fail the same way
+			line.finish(colorRed, fmt.Sprintf("stopping with error: %v", err))
+			run.Failed++
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", channelID, err))
+			fmt.Println(colorize(colorRed, targetAccountGuidance(err)))
+			audit("failed")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "subscribing failed")
+			span.End()
+			break channels
+
+		case mayBeUnavailable(err) && !transferer.channelVisible(requestCtx, channelID, spend):
+			line.finish(colorYellow, fmt.Sprintf("unavailable to the target account, it may be blocked in the account's region, marking it unavailable (%v)", err))
+			transferer.recordAttempt(index, failure)
+			transferer.setUnavailable(index)
+			run.Unavailable = append(run.Unavailable, channelID+" "+channel.Snippet.Title)
+			result = "unavailable"
+
+		case channelRefusal(err) != "":
+			// The target account can see the channel, so it is the channel
+			// itself refusing, which no later run will get past
+			failure.Permanent = true
+			line.finish(colorYellow, fmt.Sprintf("%s, skipping it from now on (%v)", channelRefusal(err), err))
+			transferer.recordAttempt(index, failure)
+			run.Failed++
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", channelID, err))
+			result = "skipped"
+
+			refused = append(refused, failure)
+			if failures.failed(err) {
+				// The streak points to the account, so its channels are
+				// left to be tried again
+				transferer.mu.Lock()
+				for _, failure := range refused[max(0, len(refused)-failures.count):] {
+					failure.Permanent = false
+				}
+				transferer.mu.Unlock()
+				fmt.Println(colorize(colorRed, accountFailureGuidance(err, failures.count)))
+				audit(result)
+				span.End()
+				break channels
+			}
+
+		default:
+			// A channel not found without a reason is only skipped once
+			// it fails the same way twice, so a passing problem with the
+			// whole account doesn't get every channel skipped
+			previous := channelStatus.Failure
+			failure.Permanent = isPermanentFailure(err) && previous != nil && previous.Reason == failure.Reason
+			if failure.Permanent {
+				line.finish(colorRed, fmt.Sprintf("failed again with the same error, skipping it from now on: %v", err))
+			} else {
+				line.finish(colorRed, fmt.Sprintf("stopping with error: %v", err))
+			}
+			transferer.recordAttempt(index, failure)
+			run.Failed++
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", channelID, err))
+			result = "failed"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "subscribing failed")
+
+			if failures.failed(err) {
+				fmt.Println(colorize(colorRed, accountFailureGuidance(err, failures.count)))
+				audit(result)
+				span.End()
+				break channels
+			}
+		}
+
+		audit(result)
+		span.SetAttributes(attribute.String("result", result))
+		span.End()
+		channelsProcessed.Add(requestCtx, 1, metric.WithAttributes(attribute.String("result", result)))
+
+		if options.processed != nil {
+			options.processed(channel)
+		}
+	}
+
+	run.Finished = options.clock()
+	if len(run.Unavailable) > 0 {
+		fmt.Printf("%s channels are unavailable to the target account, possibly because they are blocked in its region:\n", formatCount(len(run.Unavailable)))
+		for _, channel := range run.Unavailable {
+			fmt.Printf("  %s\n", channel)
+		}
+	}
+	runSpan.SetAttributes(attribute.Int("imported", run.Imported), attribute.Int("failed", run.Failed), attribute.Int("quota.used", run.QuotaUsed))
+
+	transferer.mu.Lock()
+	transferer.state.Runs = append(transferer.state.Runs, run)
+	transferer.mu.Unlock()
+
+	return run, transferer.Save()
+}
+
+func (transferer *Transferer) setImported(index int) {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	transferer.state.Channels[index].Imported = true
+}
+
+// subscribedWithin reports whether a subscription was made after after and
+// before before, either of which may be zero for no limit. Subscriptions
+// without a date, such as those imported from files, are always within.
+func subscribedWithin(subscription *youtube.Subscription, after, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+
+	subscribed, err := time.Parse(time.RFC3339, subscription.Snippet.PublishedAt)
+	if err != nil {
+		return true
+	}
+	return (after.IsZero() || subscribed.After(after)) && (before.IsZero() || subscribed.Before(before))
+}
+
+// parseDate parses a date given on the command line, either as 2006-01-02
+// in local time or as an RFC 3339 timestamp. Empty is zero.
+func parseDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", date, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, date)
+}
+
+// recordAttempt counts an attempt to subscribe to a channel and records
+// why it failed, nil if it didn't.
+func (transferer *Transferer) recordAttempt(index int, failure *channelFailure) {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	transferer.state.Channels[index].Attempts++
+	transferer.state.Channels[index].Failure = failure
+}
+
+func (transferer *Transferer) setUnavailable(index int) {
+	transferer.mu.Lock()
+	defer transferer.mu.Unlock()
+
+	transferer.state.Channels[index].Unavailable = true
+}
+
+// channelVisible reports whether the target account can look up a channel,
+// counting the lookup's quota with spend before making it. If the lookup
+// doesn't fit in the budget or fails, the channel is assumed to be visible,
+// so the failure is treated like any other.
+func (transferer *Transferer) channelVisible(ctx context.Context, channelID string, spend func(cost int) error) bool {
+	if err := spend(listCost); err != nil {
+		return true
+	}
+	response, err := transferer.target.Channels.List([]string{"id"}).Id(channelID).Context(ctx).Do()
+	return err != nil || len(response.Items) > 0
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/youtube/v3"
+)
+
+// undoCommand unsubscribes the target account from the channels the tool
+// subscribed it to, going by the audit log, optionally only those of one
+// run. Channels the account was already subscribed to are left alone.
+func undoCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("undo", flag.ExitOnError)
+	runNumber := flags.Int("run", 0, "only undo this run, numbered as listed by history (0 undoes every run)")
+	yes := flags.Bool("yes", false, "unsubscribe without asking for confirmation")
+	addAPIFlags(flags)
+	addStateFileFlag(flags)
+	addAuditLogFlag(flags)
+	return flags, func(args []string) {
+		if auditLogFile == "" {
+			log.Fatalf("Undoing needs the audit log, pass -audit-log")
+		}
+		entries, err := readAuditLog(auditLogFile)
+		if err != nil {
+			log.Fatalf("Unable to read the audit log: %v", err)
+		}
+
+		// Run numbers start over in every state file and after a reset, so the
+		// run is told apart in the audit log by the ID the state file has for it
+		state, err := readStateFromFile(stateFile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Unable to read state file: %v", err)
+		} else if state == nil {
+			state = &importState{}
+		}
+		if *runNumber < 0 || *runNumber > len(state.Runs) {
+			log.Fatalf("The state file has no run %d, see history for its runs", *runNumber)
+		}
+
+		ctx := context.Background()
+		service := getService(ctx, "target", youtube.YoutubeForceSslScope)
+		channel, err := authorizedChannel(ctx, service)
+		if err != nil {
+			log.Fatalf("Unable to look up the target account: %v", err)
+		}
+		var channelID string
+		if channel != nil {
+			channelID = channel.Id
+		}
+
+		undo := subscriptionsToUndo(entries, channelID, state.Runs, *runNumber)
+		if len(undo) == 0 {
+			fmt.Println("Nothing to undo")
+			return
+		}
+		for _, entry := range undo {
+			fmt.Printf("  %s (%s, run %d)\n", entry.Title, entry.ChannelID, entry.Run)
+		}
+		if !*yes && !confirm(fmt.Sprintf("Unsubscribe the target account from these %s channels, using %s quota units?",
+			formatCount(len(undo)), formatCount(len(undo)*subscriptionDeleteCost))) {
+			return
+		}
+
+		audit := newAuditLog(auditLogFile)
+		undone := make(map[string]bool)
+		for _, entry := range undo {
+			subscription := &youtube.Subscription{
+				Id: entry.SubscriptionID,
+				Snippet: &youtube.SubscriptionSnippet{
+					Title:      entry.Title,
+					ResourceId: &youtube.ResourceId{ChannelId: entry.ChannelID, Kind: "youtube#channel"},
+				},
+			}
+
+			line := startStatusLine(fmt.Sprintf("Unsubscribing from %s: ", displayTitle(entry.Title, titleWidth)))
+			err := service.Subscriptions.Delete(entry.SubscriptionID).Context(ctx).Do()
+			recordUnsubscribe(audit, "target", subscription, err)
+			if isNotFound(err) {
+				line.finish(colorYellow, "already unsubscribed")
+				undone[entry.ChannelID] = true
+				continue
+			} else if err != nil {
+				line.finish(colorRed, fmt.Sprintf("stopping with error: %v", err))
+				break
+			}
+			line.finish(colorGreen, "unsubscribed")
+			undone[entry.ChannelID] = true
+		}
+
+		markUndone(undone, *runNumber == 0 && len(undone) == len(undo))
+	}
+}
+
+// readAuditLog returns the entries of an audit log, oldest first.
+func readAuditLog(file string) ([]auditEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", number, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// subscriptionsToUndo returns the subscriptions the tool created on the
+// target account's channel that it hasn't removed since, only those of the
+// run numbered run of the state file's runs if not zero. Subscriptions
+// logged for another channel are left out, and so are those logged without
+// a channel unless one of the state file's runs made them.
+func subscriptionsToUndo(entries []auditEntry, channelID string, runs []RunRecord, run int) []auditEntry {
+	removed := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Action == "unsubscribe" && (entry.Result == "unsubscribed" || entry.Result == "notFound") {
+			removed[entry.SubscriptionID] = true
+		}
+	}
+	inRuns := func(entry auditEntry) bool {
+		for index, record := range runs {
+			if entry.inRun(index+1, record) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var undo []auditEntry
+	for _, entry := range entries {
+		if entry.Action != "subscribe" || entry.Account != "target" || entry.Result != "imported" || entry.SubscriptionID == "" {
+			continue
+		}
+		if removed[entry.SubscriptionID] || (run != 0 && !entry.inRun(run, runs[run-1])) {
+			continue
+		}
+		if entry.AccountChannel != "" && channelID != "" && entry.AccountChannel != channelID {
+			continue
+		}
+		if entry.AccountChannel == "" && run == 0 && !inRuns(entry) {
+			continue
+		}
+		undo = append(undo, entry)
+	}
+	return undo
+}
+
+// inRun reports whether the entry was logged by the run numbered number.
+// Entries logged before runs had IDs are matched by the run's number and
+// when it ran instead.
+func (entry auditEntry) inRun(number int, run RunRecord) bool {
+	if entry.RunID != "" || run.ID != "" {
+		return entry.RunID == run.ID
+	}
+	return entry.Run == number && !entry.Time.Before(run.Started) && !entry.Time.After(run.Finished)
+}
+
+// markUndone marks the undone channels as pending in the state file, so a
+// later transfer subscribes to them again. When every run was undone in
+// full the target channel recorded in the state file is forgotten too, so
+// the transfer can be redone into another account.
+func markUndone(undone map[string]bool, everything bool) {
+	if len(undone) == 0 {
+		return
+	}
+	state, err := readStateFromFile(stateFile)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Fatalf("Unable to read state file: %v", err)
+	}
+
+	for index := range state.Channels {
+		channelStatus := &state.Channels[index]
+		if undone[channelStatus.Channel.Snippet.ResourceId.ChannelId] {
+			channelStatus.Imported = false
+		}
+	}
+	if everything {
+		delete(state.Accounts, "target")
+	}
+	if err := writeStateToFile(stateFile, state); err != nil {
+		log.Fatalf("Unable to save state: %v", err)
+	}
+}
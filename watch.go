@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// watchCommand periodically lists the source account's subscriptions and
+// notifies about channels subscribed to or unsubscribed from since the last
+// listing, without changing anything, to help decide when to transfer.
+func watchCommand() (*flag.FlagSet, func(args []string)) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", 6*time.Hour, "how often to check the source account for changes")
+	var notify repeatedFlag
+	flags.Var(&notify, "notify", "send changes to NAME=TARGET, can be repeated: "+strings.Join(notifierNames(), ", "))
+	notifyOptions := addNotifierFlags(flags, "changes")
+	addAPIFlags(flags)
+	return flags, func(args []string) {
+		notifiers, err := newNotifiers(notify, *notifyOptions)
+		if err != nil {
+			log.Fatalf("Unable to set up notifications: %v", err)
+		}
+		defer notifiers.close()
+
+		ctx := handleShutdown(notifiers.close)
+		sourceService := getService(ctx, "source", youtube.YoutubeReadonlyScope)
+		list := func() *youtube.Service { return sourceService }
+
+		var previous []*youtube.Subscription
+		if sourceChannel := accountChannels()["source"]; sourceChannel != "" {
+			if snapshot, err := readSourceSnapshot(sourceChannel); err == nil {
+				fmt.Printf("Comparing against the %s subscriptions listed on %s\n", formatCount(len(snapshot.Subscriptions)), formatDateTime(snapshot.Fetched))
+				previous = snapshot.Subscriptions
+			} else if !os.IsNotExist(err) {
+				log.Fatalf("Unable to read %s: %v", sourceSnapshotFile(sourceChannel), err)
+			}
+		}
+
+		for {
+			current, err := sourceSubscriptions(ctx, list, true)
+			if err != nil {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Unable to list the source subscriptions, trying again in %v: %v", *interval, err)))
+			} else if previous == nil {
+				fmt.Printf("Listed %s subscriptions, watching for changes every %v\n", formatCount(len(current)), *interval)
+				previous = current
+			} else {
+				added, removed := diffSubscriptions(previous, current)
+				if len(added) > 0 || len(removed) > 0 {
+					message := describeChanges(added, removed)
+					fmt.Println(message)
+
+					update := progressUpdate{State: "changed", Total: len(current), Message: message, Added: added, Removed: removed}
+					if err := notifiers.notify(update); err != nil {
+						log.Printf("Unable to send notification: %v", err)
+					}
+				} else {
+					fmt.Printf("No changes to the %s subscriptions\n", formatCount(len(current)))
+				}
+				previous = current
+			}
+
+			select {
+			case <-time.After(*interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// diffSubscriptions returns the titles of the channels subscribed to in
+// current but not previous, and the other way around.
+func diffSubscriptions(previous, current []*youtube.Subscription) (added, removed []string) {
+	titles := func(subscriptions []*youtube.Subscription) map[string]string {
+		byID := make(map[string]string)
+		for _, subscription := range subscriptions {
+			byID[subscription.Snippet.ResourceId.ChannelId] = subscription.Snippet.Title
+		}
+		return byID
+	}
+	previousTitles, currentTitles := titles(previous), titles(current)
+
+	for _, subscription := range current {
+		if _, ok := previousTitles[subscription.Snippet.ResourceId.ChannelId]; !ok {
+			added = append(added, subscription.Snippet.Title)
+		}
+	}
+	for _, subscription := range previous {
+		if _, ok := currentTitles[subscription.Snippet.ResourceId.ChannelId]; !ok {
+			removed = append(removed, subscription.Snippet.Title)
+		}
+	}
+	return added, removed
+}
+
+// describeChanges words the changes, e.g. "You subscribed to A and B, and
+// unsubscribed from C".
+func describeChanges(added, removed []string) string {
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, "subscribed to "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "unsubscribed from "+strings.Join(removed, ", "))
+	}
+	return "You " + strings.Join(parts, ", and ")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookNotifier POSTs each progress update as JSON to a URL, which works
+// with ntfy, Home Assistant webhooks and most automation services.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(url string, options notifierOptions) (notifier, error) {
+	if url == "" {
+		return nil, errors.New("expected a URL, e.g. webhook=https://example.com/hook")
+	}
+	return &webhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (webhook *webhookNotifier) notify(update progressUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	response, err := webhook.client.Post(webhook.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with %s", webhook.url, response.Status)
+	}
+	return nil
+}
+
+func (webhook *webhookNotifier) close() {}